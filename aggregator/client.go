@@ -1,8 +1,15 @@
 package aggregator
 
 import (
+	"context"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/ava-labs/avalanche-tooling-sdk-go/interchain"
 	"github.com/ava-labs/avalanchego/ids"
@@ -12,6 +19,57 @@ import (
 	"github.com/ava-labs/subnet-evm/warp/messages"
 )
 
+var (
+	// ErrInvalidValidationID is returned when a validation ID to sign for
+	// is not a valid CB58 ID.
+	ErrInvalidValidationID = errors.New("invalid validation ID")
+
+	// ErrNilMessage is returned by SubmitAggregateRequest when it is given
+	// no message to sign.
+	ErrNilMessage = errors.New("unsigned message is nil")
+
+	// ErrSigningFailed is wrapped by SubmitAggregateRequest errors when the
+	// aggregator ran the aggregation and reported that it failed, which is
+	// what happens when validators decline to sign an uptime.
+	ErrSigningFailed = errors.New("signature aggregation failed")
+
+	// ErrAggregatorUnavailable is wrapped by SubmitAggregateRequest errors
+	// when the aggregator gave no answer of its own: it was unreachable,
+	// timed out, or something in front of it failed. Those say nothing about
+	// the uptime, so an uptime search must not count them as a declined
+	// value. Cancellation and requests the aggregator rejects as bad are
+	// reported with neither sentinel.
+	ErrAggregatorUnavailable = errors.New("signature aggregator unavailable")
+)
+
+// httpStatusRE picks the status code and body out of the SDK's error for a
+// non-2xx aggregator response, which is all the SDK passes on of it.
+var httpStatusRE = regexp.MustCompile(`(?s)non-2xx status code: (\d+), body: (.*)`)
+
+// classifyError wraps an error from the SDK's SignMessage with the sentinel
+// for what happened. The aggregator reports a failed aggregation, such as
+// too little stake signing, as a 500 with a JSON error body; a 4xx means it
+// rejected the request. Anything else, including a 5xx without a JSON body
+// (a proxy's error page, say) or no response at all, means it wasn't heard
+// from.
+func classifyError(err error) error {
+	m := httpStatusRE.FindStringSubmatch(err.Error())
+	if m == nil {
+		return fmt.Errorf("%w: %w", ErrAggregatorUnavailable, err)
+	}
+	status, _ := strconv.Atoi(m[1])
+	body := strings.TrimSpace(m[2])
+
+	switch {
+	case status == http.StatusInternalServerError && strings.HasPrefix(body, "{") && json.Valid([]byte(body)):
+		return fmt.Errorf("%w: %w", ErrSigningFailed, err)
+	case status >= 400 && status < 500 && status != http.StatusTooManyRequests:
+		return fmt.Errorf("aggregator rejected the request: %w", err)
+	default:
+		return fmt.Errorf("%w: %w", ErrAggregatorUnavailable, err)
+	}
+}
+
 type Client struct {
 	aggregatorURL   string
 	sourceChainID   ids.ID
@@ -73,10 +131,11 @@ func (c *Client) PackValidationUptimeMessage(
 	uptimeSeconds uint64,
 	networkID uint32,
 ) (*warp.UnsignedMessage, error) {
-	uptimePayload, err := messages.NewValidatorUptime(
-		ids.FromStringOrPanic(validationID),
-		uptimeSeconds,
-	)
+	valID, err := ids.FromString(validationID)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidValidationID, validationID, err)
+	}
+	uptimePayload, err := messages.NewValidatorUptime(valID, uptimeSeconds)
 	if err != nil {
 		return nil, fmt.Errorf("generate uptime payload: %w", err)
 	}
@@ -98,32 +157,67 @@ func (c *Client) PackValidationUptimeMessage(
 	return unsignedMsg, nil
 }
 
+// ParseValidationUptimeMessage returns the validation ID and uptime that
+// msg, as built by PackValidationUptimeMessage, attests to.
+func ParseValidationUptimeMessage(msg *warp.Message) (ids.ID, uint64, error) {
+	addressedCall, err := payload.ParseAddressedCall(msg.UnsignedMessage.Payload)
+	if err != nil {
+		return ids.Empty, 0, fmt.Errorf("parse addressed call: %w", err)
+	}
+	uptime, err := messages.ParseValidatorUptime(addressedCall.Payload)
+	if err != nil {
+		return ids.Empty, 0, fmt.Errorf("parse uptime payload: %w", err)
+	}
+	return uptime.ValidationID, uptime.TotalUptime, nil
+}
+
+// SubmitAggregateRequest asks the aggregator to collect quorum signatures for
+// unsignedMessage. The SDK call itself takes no context, so it runs on its own
+// goroutine and this returns as soon as ctx is done; the abandoned request
+// finishes in the background and its result is dropped.
 func (c *Client) SubmitAggregateRequest(
+	ctx context.Context,
 	unsignedMessage *warp.UnsignedMessage,
 ) (*warp.Message, error) {
 	if unsignedMessage == nil {
-		return nil, fmt.Errorf("unsigned message is nil")
+		return nil, ErrNilMessage
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("aggregate signatures: %w", err)
 	}
 
 	// uptime proofs have no justification
 	messageHex := hex.EncodeToString(unsignedMessage.Bytes())
 	justificationHex := ""
 
-	signedMsg, err := interchain.SignMessage(
-		c.logger,
-		c.aggregatorURL,
-		messageHex,
-		justificationHex,
-		c.signingSubnetID,
-		c.quorum,
-		0,
-		interchain.WithMaxRetries(1),
-		interchain.WithInitialBackoff(1),
-		interchain.WithRequestFormat(interchain.RequestFormatKebabCase),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("aggregate signatures: %w", err)
+	type signResult struct {
+		msg *warp.Message
+		err error
+	}
+	done := make(chan signResult, 1)
+	go func() {
+		signedMsg, err := interchain.SignMessage(
+			c.logger,
+			c.aggregatorURL,
+			messageHex,
+			justificationHex,
+			c.signingSubnetID,
+			c.quorum,
+			0,
+			interchain.WithMaxRetries(1),
+			interchain.WithInitialBackoff(1),
+			interchain.WithRequestFormat(interchain.RequestFormatKebabCase),
+		)
+		done <- signResult{msg: signedMsg, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("aggregate signatures: %w", ctx.Err())
+	case res := <-done:
+		if res.err == nil {
+			return res.msg, nil
+		}
+		return nil, classifyError(res.err)
 	}
-
-	return signedMsg, nil
 }
@@ -0,0 +1,80 @@
+package aggregator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  string
+		want error // nil: neither sentinel
+	}{
+		{
+			name: "too few signatures",
+			err:  `signature aggregator returned non-2xx status code: 500, body: {"error":"failed to collect a threshold of signatures"}`,
+			want: ErrSigningFailed,
+		},
+		{
+			name: "too little stake connected",
+			err:  `signature aggregator returned non-2xx status code: 500, body: {"error":"failed to connect to a threshold of stake"}` + "\n",
+			want: ErrSigningFailed,
+		},
+		{
+			name: "after SDK retries",
+			err:  `failed after 3 attempts, last error: signature aggregator returned non-2xx status code: 500, body: {"error":"failed to collect a threshold of signatures"}`,
+			want: ErrSigningFailed,
+		},
+		{
+			name: "proxy error page",
+			err:  `signature aggregator returned non-2xx status code: 500, body: <html><body>Internal Server Error</body></html>`,
+			want: ErrAggregatorUnavailable,
+		},
+		{
+			name: "bad gateway",
+			err:  `signature aggregator returned non-2xx status code: 502, body: {"error":"upstream unavailable"}`,
+			want: ErrAggregatorUnavailable,
+		},
+		{
+			name: "rate limited",
+			err:  `signature aggregator returned non-2xx status code: 429, body: too many requests`,
+			want: ErrAggregatorUnavailable,
+		},
+		{
+			name: "rejected request",
+			err:  `signature aggregator returned non-2xx status code: 400, body: {"error":"invalid message"}`,
+			want: nil,
+		},
+		{
+			name: "connection refused",
+			err:  `failed to make request: Post "http://localhost:9090/aggregate-signatures": dial tcp 127.0.0.1:9090: connect: connection refused`,
+			want: ErrAggregatorUnavailable,
+		},
+		{
+			name: "timeout",
+			err:  `failed to make request: Post "http://localhost:9090/aggregate-signatures": context deadline exceeded (Client.Timeout exceeded while awaiting headers)`,
+			want: ErrAggregatorUnavailable,
+		},
+		{
+			name: "local config error",
+			err:  `quorum percentage cannot be greater than 100`,
+			want: ErrAggregatorUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cause := errors.New(tt.err)
+			got := classifyError(cause)
+			if !errors.Is(got, cause) {
+				t.Errorf("classifyError dropped the cause: %v", got)
+			}
+			for _, sentinel := range []error{ErrSigningFailed, ErrAggregatorUnavailable} {
+				if errors.Is(got, sentinel) != (sentinel == tt.want) {
+					t.Errorf("classifyError(%q) = %v, want it to wrap %v", tt.err, got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+)
+
+// fees prices a new dynamic-fee transaction: the node's suggested tip, and a
+// fee cap of twice the current base fee plus that tip, so the transaction
+// stays includable through a few blocks of rising base fees. Both are held
+// to the configured caps; a base fee already above MaxFeeCap is an error,
+// since nothing priced under the cap could be mined.
+func (s *Sender) fees(ctx context.Context) (tipCap, feeCap *big.Int, err error) {
+	tipCap, err = s.client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get gas tip cap: %w", err)
+	}
+
+	head, err := s.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get latest header: %w", err)
+	}
+	if head.BaseFee == nil {
+		return nil, nil, fmt.Errorf("chain does not report a base fee; dynamic-fee transactions are not supported")
+	}
+
+	feeCap = new(big.Int).Mul(head.BaseFee, big.NewInt(2))
+	feeCap.Add(feeCap, tipCap)
+
+	tipCap, feeCap = s.capFees(tipCap, feeCap)
+	if feeCap.Cmp(head.BaseFee) < 0 {
+		return nil, nil, fmt.Errorf("base fee %s wei is above the max fee cap %s wei", head.BaseFee, feeCap)
+	}
+	return tipCap, feeCap, nil
+}
+
+// capFees holds tipCap and feeCap to the configured maximums and keeps the
+// tip within the fee cap, as nodes require.
+func (s *Sender) capFees(tipCap, feeCap *big.Int) (*big.Int, *big.Int) {
+	if s.cfg.MaxTipCap != nil && tipCap.Cmp(s.cfg.MaxTipCap) > 0 {
+		tipCap = s.cfg.MaxTipCap
+	}
+	if s.cfg.MaxFeeCap != nil && feeCap.Cmp(s.cfg.MaxFeeCap) > 0 {
+		feeCap = s.cfg.MaxFeeCap
+	}
+	if tipCap.Cmp(feeCap) > 0 {
+		tipCap = feeCap
+	}
+	return tipCap, feeCap
+}
+
+// bump returns v raised by percent.
+func bump(v *big.Int, percent int) *big.Int {
+	out := new(big.Int).Mul(v, big.NewInt(int64(100+percent)))
+	return out.Div(out, big.NewInt(100))
+}
+
+func maxBig(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+// GweiToWei converts a gwei amount from the config to wei. Zero or negative
+// amounts mean "no cap" and return nil.
+func GweiToWei(gwei float64) *big.Int {
+	if gwei <= 0 {
+		return nil
+	}
+	wei, _ := new(big.Float).Mul(big.NewFloat(gwei), big.NewFloat(1e9)).Int(nil)
+	return wei
+}
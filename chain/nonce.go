@@ -0,0 +1,58 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ava-labs/libevm/common"
+	"github.com/ava-labs/libevm/ethclient"
+)
+
+// NonceManager hands out nonces for one sender address so transactions can
+// be sent back to back without waiting for each other.
+//
+// Every Next call also reads the node's pending nonce and uses whichever is
+// higher, so transactions sent from the same key by another process are
+// picked up instead of colliding. After a failed send, Resync drops the
+// local counter so the node's view wins again.
+type NonceManager struct {
+	client  *ethclient.Client
+	address common.Address
+
+	mu     sync.Mutex
+	next   uint64
+	synced bool
+}
+
+func NewNonceManager(client *ethclient.Client, address common.Address) *NonceManager {
+	return &NonceManager{client: client, address: address}
+}
+
+// Next reserves and returns the next nonce.
+func (m *NonceManager) Next(ctx context.Context) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pending, err := m.client.PendingNonceAt(ctx, m.address)
+	if err != nil {
+		return 0, fmt.Errorf("get pending nonce: %w", err)
+	}
+
+	nonce := pending
+	if m.synced && m.next > nonce {
+		nonce = m.next
+	}
+	m.next = nonce + 1
+	m.synced = true
+	return nonce, nil
+}
+
+// Resync forgets the local counter. Call it when a reserved nonce was not
+// used, e.g. because signing or sending failed, so the gap is not carried
+// forward.
+func (m *NonceManager) Resync() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.synced = false
+}
@@ -0,0 +1,147 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ava-labs/libevm"
+	"github.com/ava-labs/libevm/accounts/abi"
+	"github.com/ava-labs/libevm/common"
+	"github.com/ava-labs/libevm/common/hexutil"
+	"github.com/ava-labs/libevm/core/types"
+	"github.com/ava-labs/libevm/crypto"
+	"github.com/ava-labs/libevm/ethclient"
+	"github.com/ava-labs/libevm/rpc"
+)
+
+var (
+	// ErrReceiptTimeout means a sent transaction was not mined within the
+	// receipt timeout. It may still land later.
+	ErrReceiptTimeout = errors.New("timed out waiting for transaction receipt")
+
+	// ErrReverted is matched by every *RevertError.
+	ErrReverted = errors.New("transaction reverted")
+)
+
+// RevertError describes a mined transaction whose receipt has a failed
+// status. Reason is the decoded custom error or revert string; Err is the
+// sentinel it maps to in the caller's signature table, if any.
+type RevertError struct {
+	TxHash common.Hash
+	Reason string
+	Err    error
+}
+
+func (e *RevertError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("transaction %s reverted: %s (%v)", e.TxHash.Hex(), e.Reason, e.Err)
+	}
+	return fmt.Sprintf("transaction %s reverted: %s", e.TxHash.Hex(), e.Reason)
+}
+
+func (e *RevertError) Unwrap() []error {
+	if e.Err != nil {
+		return []error{ErrReverted, e.Err}
+	}
+	return []error{ErrReverted}
+}
+
+// CheckReceipt returns nil if receipt reports success. Otherwise it replays
+// tx from `from` against the parent of the block it was mined in to recover
+// the revert data, decodes it against signatures (as in
+// validatormanager.ErrorSignatureToError), and returns a *RevertError.
+func CheckReceipt(
+	ctx context.Context,
+	client *ethclient.Client,
+	from common.Address,
+	tx *types.Transaction,
+	receipt *types.Receipt,
+	signatures map[string]error,
+) error {
+	if receipt.Status == types.ReceiptStatusSuccessful {
+		return nil
+	}
+
+	revertErr := &RevertError{TxHash: tx.Hash(), Reason: "unknown reason"}
+
+	msg := ethereum.CallMsg{
+		From:       from,
+		To:         tx.To(),
+		Gas:        tx.Gas(),
+		Value:      tx.Value(),
+		Data:       tx.Data(),
+		AccessList: tx.AccessList(),
+	}
+	var parent *big.Int
+	if receipt.BlockNumber != nil && receipt.BlockNumber.Sign() > 0 {
+		parent = new(big.Int).Sub(receipt.BlockNumber, big.NewInt(1))
+	}
+
+	if _, callErr := client.CallContract(ctx, msg, parent); callErr != nil {
+		if data, ok := revertData(callErr); ok {
+			revertErr.Reason, revertErr.Err = DecodeRevert(data, signatures)
+		} else {
+			revertErr.Reason = callErr.Error()
+		}
+	} else if receipt.GasUsed >= tx.Gas() {
+		// The replay passed on the parent state, so unless the revert hinged
+		// on a transaction earlier in the same block, it ran out of gas.
+		revertErr.Reason = "out of gas"
+	}
+	return revertErr
+}
+
+// DecodeCallError decodes the revert data carried by a failed eth_call or
+// gas estimate, so a transaction that would revert fails with a readable
+// reason before it is sent. The result wraps the matched sentinel from
+// signatures, if any. Errors without revert data are returned unchanged.
+func DecodeCallError(err error, signatures map[string]error) error {
+	data, ok := revertData(err)
+	if !ok {
+		return err
+	}
+	reason, sentinel := DecodeRevert(data, signatures)
+	if sentinel != nil {
+		return fmt.Errorf("execution reverted: %s: %w", reason, sentinel)
+	}
+	return fmt.Errorf("execution reverted: %s", reason)
+}
+
+// DecodeRevert turns revert data into a readable reason. Custom errors are
+// matched by selector against the signatures in the table and return the
+// mapped sentinel; Error(string) reverts return their message.
+func DecodeRevert(data []byte, signatures map[string]error) (string, error) {
+	if len(data) < 4 {
+		return "empty revert data", nil
+	}
+	if reason, err := abi.UnpackRevert(data); err == nil {
+		return reason, nil
+	}
+	for signature, sentinel := range signatures {
+		if bytes.Equal(crypto.Keccak256([]byte(signature))[:4], data[:4]) {
+			return signature, sentinel
+		}
+	}
+	return fmt.Sprintf("unknown custom error %s", hexutil.Encode(data[:4])), nil
+}
+
+// revertData pulls the raw revert payload out of an eth_call error.
+func revertData(err error) ([]byte, bool) {
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return nil, false
+	}
+	hexData, ok := dataErr.ErrorData().(string)
+	if !ok || !strings.HasPrefix(hexData, "0x") {
+		return nil, false
+	}
+	data, err := hexutil.Decode(hexData)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
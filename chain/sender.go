@@ -0,0 +1,327 @@
+package chain
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"uptime-service/logging"
+
+	ethereum "github.com/ava-labs/libevm"
+	"github.com/ava-labs/libevm/common"
+	"github.com/ava-labs/libevm/core/types"
+	"github.com/ava-labs/libevm/crypto"
+	"github.com/ava-labs/libevm/ethclient"
+)
+
+const (
+	receiptPollInterval = 2 * time.Second
+
+	// minGasBumpPercent is the smallest price bump nodes accept for a
+	// replacement transaction.
+	minGasBumpPercent = 10
+)
+
+// SenderConfig controls how a Sender prices its transactions, how long it
+// waits for receipts and how it replaces transactions that are not getting
+// mined.
+type SenderConfig struct {
+	// MaxFeeCap and MaxTipCap bound the fee cap and priority tip of every
+	// transaction, replacements included. Nil means no bound.
+	MaxFeeCap *big.Int
+	MaxTipCap *big.Int
+	// GasLimitMarginPercent is added on top of the gas estimate of requests
+	// that don't set their own gas limit.
+	GasLimitMarginPercent int
+
+	// ReceiptTimeout bounds the wait for a transaction, counted from its
+	// first broadcast and including any replacements.
+	ReceiptTimeout time.Duration
+	// ReplaceAfter is how long a transaction may sit unmined before it is
+	// re-sent with the same nonce and higher fees.
+	ReplaceAfter time.Duration
+	// GasBumpPercent is how much each replacement raises the fee cap and
+	// tip. Nodes reject replacements that bump by less than 10%, so smaller
+	// values are raised to 10.
+	GasBumpPercent int
+}
+
+// Sender signs and broadcasts transactions from the service key. It is
+// meant to be shared by every client that sends from that key, so that all
+// of them draw nonces from the same NonceManager.
+type Sender struct {
+	client *ethclient.Client
+	key    *ecdsa.PrivateKey
+	from   common.Address
+	nonces *NonceManager
+	cfg    SenderConfig
+
+	chainIDMu sync.Mutex
+	chainID   *big.Int // cached once fetched; nil until then
+}
+
+// NewSender dials rpcURL and prepares to send from privateKeyHex.
+func NewSender(rpcURL, privateKeyHex string, cfg SenderConfig) (*Sender, error) {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(privateKeyHex, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+	from := crypto.PubkeyToAddress(key.PublicKey)
+
+	if cfg.GasBumpPercent < minGasBumpPercent {
+		cfg.GasBumpPercent = minGasBumpPercent
+	}
+
+	client, err := ethclient.Dial(rpcURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect EVM client: %w", err)
+	}
+
+	return &Sender{
+		client: client,
+		key:    key,
+		from:   from,
+		nonces: NewNonceManager(client, from),
+		cfg:    cfg,
+	}, nil
+}
+
+// Client returns the RPC client, for reads and simulations.
+func (s *Sender) Client() *ethclient.Client { return s.client }
+
+// From returns the address transactions are sent from.
+func (s *Sender) From() common.Address { return s.from }
+
+// TxRequest is a contract call to be sent as a transaction. A zero Gas is
+// filled in from EstimateGas plus the configured margin.
+type TxRequest struct {
+	To         common.Address
+	Data       []byte
+	Gas        uint64
+	AccessList types.AccessList
+}
+
+// PendingTx is a broadcast transaction that has not been confirmed yet. A
+// stuck transaction may be replaced while waiting, so it tracks every
+// version sent under its nonce; any one of them may be the one that lands.
+type PendingTx struct {
+	req         TxRequest
+	nonce       uint64
+	tipCap      *big.Int
+	feeCap      *big.Int
+	sent        []*types.Transaction
+	firstSent   time.Time
+	lastAttempt time.Time
+}
+
+// Hash returns the hash of the most recently broadcast version, or the zero
+// hash if none was.
+func (p *PendingTx) Hash() common.Hash {
+	if len(p.sent) == 0 {
+		return common.Hash{}
+	}
+	return p.sent[len(p.sent)-1].Hash()
+}
+
+// Nonce returns the nonce the transaction was sent with.
+func (p *PendingTx) Nonce() uint64 { return p.nonce }
+
+// Send signs and broadcasts req as a dynamic-fee transaction with the next
+// nonce and returns without waiting for it to be mined. A request that would
+// revert fails at gas estimation, before a nonce is taken; pass the error to
+// DecodeCallError for a readable reason. If anything fails after that but
+// before the node accepts the transaction, the nonce manager is re-synced so
+// the nonce is reused.
+func (s *Sender) Send(ctx context.Context, req TxRequest) (*PendingTx, error) {
+	if req.Gas == 0 {
+		gas, err := s.EstimateGas(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		req.Gas = gas
+	}
+
+	tipCap, feeCap, err := s.fees(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := s.nonces.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &PendingTx{req: req, nonce: nonce, tipCap: tipCap, feeCap: feeCap}
+	if err := s.broadcast(ctx, p); err != nil {
+		s.nonces.Resync()
+		return nil, err
+	}
+	p.firstSent = p.lastAttempt
+	return p, nil
+}
+
+// EstimateGas estimates req from the sender's address and adds
+// GasLimitMarginPercent on top.
+func (s *Sender) EstimateGas(ctx context.Context, req TxRequest) (uint64, error) {
+	gas, err := s.client.EstimateGas(ctx, ethereum.CallMsg{
+		From:       s.from,
+		To:         &req.To,
+		Data:       req.Data,
+		AccessList: req.AccessList,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("estimate gas: %w", err)
+	}
+	return gas + gas*uint64(s.cfg.GasLimitMarginPercent)/100, nil
+}
+
+// broadcast signs p at its current fees and sends it.
+func (s *Sender) broadcast(ctx context.Context, p *PendingTx) error {
+	chainID, err := s.loadChainID(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := types.SignNewTx(s.key, types.LatestSignerForChainID(chainID), &types.DynamicFeeTx{
+		ChainID:    chainID,
+		Nonce:      p.nonce,
+		GasTipCap:  p.tipCap,
+		GasFeeCap:  p.feeCap,
+		Gas:        p.req.Gas,
+		To:         &p.req.To,
+		Data:       p.req.Data,
+		AccessList: p.req.AccessList,
+	})
+	if err != nil {
+		return fmt.Errorf("sign tx: %w", err)
+	}
+
+	if err := s.client.SendTransaction(ctx, tx); err != nil {
+		return fmt.Errorf("send tx: %w", err)
+	}
+	p.sent = append(p.sent, tx)
+	p.lastAttempt = time.Now()
+	return nil
+}
+
+// loadChainID returns the chain ID, fetching it on first use. Only a
+// successful fetch is cached, so a transient RPC failure or a cancelled ctx
+// fails just this send and the next one tries again.
+func (s *Sender) loadChainID(ctx context.Context) (*big.Int, error) {
+	s.chainIDMu.Lock()
+	defer s.chainIDMu.Unlock()
+
+	if s.chainID != nil {
+		return s.chainID, nil
+	}
+	chainID, err := s.client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get chain ID: %w", err)
+	}
+	s.chainID = chainID
+	return chainID, nil
+}
+
+// Wait blocks until one version of p is mined and checks its status with
+// CheckReceipt, decoding reverts against signatures. A transaction that goes
+// unmined for ReplaceAfter is re-sent under the same nonce with its gas price
+// raised by GasBumpPercent. Wait gives up with ErrReceiptTimeout once
+// ReceiptTimeout has passed since the first broadcast.
+func (s *Sender) Wait(ctx context.Context, p *PendingTx, signatures map[string]error) (*types.Receipt, error) {
+	deadline := p.firstSent.Add(s.cfg.ReceiptTimeout)
+	ticker := time.NewTicker(receiptPollInterval)
+	defer ticker.Stop()
+
+	for {
+		tx, receipt, err := s.findReceipt(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		if receipt != nil {
+			if err := CheckReceipt(ctx, s.client, s.from, tx, receipt, signatures); err != nil {
+				return receipt, err
+			}
+			return receipt, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: %s after %s", ErrReceiptTimeout, p.Hash().Hex(), s.cfg.ReceiptTimeout)
+		}
+		if s.cfg.ReplaceAfter > 0 && time.Since(p.lastAttempt) >= s.cfg.ReplaceAfter {
+			s.replace(ctx, p)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("wait for receipt of %s: %w", p.Hash().Hex(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// findReceipt looks for a receipt for any version of p, newest first.
+// Lookup errors other than cancellation are treated as "not mined yet".
+func (s *Sender) findReceipt(ctx context.Context, p *PendingTx) (*types.Transaction, *types.Receipt, error) {
+	for i := len(p.sent) - 1; i >= 0; i-- {
+		receipt, err := s.client.TransactionReceipt(ctx, p.sent[i].Hash())
+		if err == nil {
+			return p.sent[i], receipt, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, nil, fmt.Errorf("wait for receipt of %s: %w", p.Hash().Hex(), ctxErr)
+		}
+	}
+	return nil, nil, nil
+}
+
+// replace re-sends p with its fee cap and tip raised by GasBumpPercent, or
+// to the current market rate if that is higher. Failures are logged and the
+// wait goes on: the usual cause is that an earlier version was just mined
+// ("nonce too low"), which the next receipt lookup will find.
+func (s *Sender) replace(ctx context.Context, p *PendingTx) {
+	previous := p.Hash()
+	oldTip, oldFeeCap := p.tipCap, p.feeCap
+
+	tipCap, feeCap := bump(oldTip, s.cfg.GasBumpPercent), bump(oldFeeCap, s.cfg.GasBumpPercent)
+	if marketTip, marketFeeCap, err := s.fees(ctx); err == nil {
+		tipCap, feeCap = maxBig(tipCap, marketTip), maxBig(feeCap, marketFeeCap)
+	}
+	tipCap, feeCap = s.capFees(tipCap, feeCap)
+
+	// Don't retry on every poll whatever happens next; wait another
+	// ReplaceAfter first.
+	p.lastAttempt = time.Now()
+
+	if feeCap.Cmp(bump(oldFeeCap, minGasBumpPercent)) < 0 || tipCap.Cmp(bump(oldTip, minGasBumpPercent)) < 0 {
+		logging.Errorf(
+			"stuck tx %s (nonce %d) is at the configured fee caps and cannot be replaced; still waiting",
+			previous.Hex(),
+			p.nonce,
+		)
+		return
+	}
+
+	p.tipCap, p.feeCap = tipCap, feeCap
+	if err := s.broadcast(ctx, p); err != nil {
+		p.tipCap, p.feeCap = oldTip, oldFeeCap
+		if !errors.Is(err, context.Canceled) {
+			logging.Errorf("replace stuck tx %s (nonce %d): %v", previous.Hex(), p.nonce, err)
+		}
+		return
+	}
+	logging.Infof(
+		"replaced stuck tx %s with %s (nonce %d, fee cap %s -> %s wei, tip %s -> %s wei)",
+		previous.Hex(),
+		p.Hash().Hex(),
+		p.nonce,
+		oldFeeCap,
+		feeCap,
+		oldTip,
+		tipCap,
+	)
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -16,13 +18,59 @@ type Config struct {
 	QuorumPercentage      int      `json:"quorum_percentage"`
 	BeamRPC               string   `json:"beam_rpc"`
 	StakingManagerAddress string   `json:"contract_address"`
-	WarpMessengerAddress  string   `json:"warp_messenger_address"`
 	PrivateKey            string   `json:"private_key"`
 	LogLevel              string   `json:"log_level"`
 	NetworkID             int      `json:"network_id"`
 	DatabaseURL           string   `json:"database_url"`
 	BootstrapValidators   []string `json:"bootstrap_validators"`
 	SlackWebhookURL       string   `json:"slack_webhook_url"`
+
+	// Uptime search: computeSignedUptime stops bisecting once the highest
+	// signable uptime is pinned down to within the resolution, and makes at
+	// most UptimeSearchMaxAttempts signature requests per validator, the
+	// samples included.
+	UptimeSearchResolutionSeconds int `json:"uptime_search_resolution_seconds"`
+	UptimeSearchMaxAttempts       int `json:"uptime_search_max_attempts"`
+
+	// SigningWorkers is how many validators generate-and-submit signs
+	// concurrently. Submission stays sequential.
+	SigningWorkers int `json:"signing_workers"`
+
+	// Transaction fees: every transaction is an EIP-1559 dynamic-fee
+	// transaction whose fee cap and tip are held to TxMaxFeeGwei and
+	// TxMaxTipGwei (0 = no cap). Gas limits are estimated and raised by
+	// TxGasLimitMarginPercent.
+	TxMaxFeeGwei            float64 `json:"tx_max_fee_gwei"`
+	TxMaxTipGwei            float64 `json:"tx_max_tip_gwei"`
+	TxGasLimitMarginPercent int     `json:"tx_gas_limit_margin_percent"`
+
+	// ResolveRewardsBatchGasSharePercent is the share of the block gas limit
+	// each resolveRewards batch is sized to fill.
+	ResolveRewardsBatchGasSharePercent int `json:"resolve_rewards_batch_gas_share_percent"`
+
+	// TxReceiptTimeoutSeconds is how long a sent transaction may take to be
+	// mined before it is reported as unconfirmed.
+	TxReceiptTimeoutSeconds int `json:"tx_receipt_timeout_seconds"`
+
+	// Stuck transactions: one that is still unmined after
+	// TxReplaceAfterSeconds is re-sent with the same nonce and its gas price
+	// raised by TxGasBumpPercent.
+	TxReplaceAfterSeconds int `json:"tx_replace_after_seconds"`
+	TxGasBumpPercent      int `json:"tx_gas_bump_percent"`
+
+	// DryRun simulates transactions instead of broadcasting them and skips
+	// DB writes. Usually set with the -dry-run flag rather than in the file.
+	DryRun bool `json:"dry_run"`
+
+	// Staking epoch schedule, used by the daemon to line runs up with
+	// epoch boundaries.
+	EpochStartTimestamp  int64 `json:"epoch_start_timestamp"`
+	EpochDurationSeconds int64 `json:"epoch_duration_seconds"`
+
+	// Daemon scheduling: each cycle starts DaemonRunOffsetSeconds after an
+	// epoch boundary. DaemonRunOnStart also runs one cycle immediately.
+	DaemonRunOffsetSeconds int64 `json:"daemon_run_offset_seconds"`
+	DaemonRunOnStart       bool  `json:"daemon_run_on_start"`
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -33,12 +81,52 @@ func LoadConfig(path string) (*Config, error) {
 	defer f.Close()
 
 	cfg := &Config{
-		QuorumPercentage: 67,
-		LogLevel:         "info",
+		QuorumPercentage:       67,
+		LogLevel:               "info",
+		DaemonRunOffsetSeconds: 600,
+
+		UptimeSearchResolutionSeconds: 60,
+		UptimeSearchMaxAttempts:       20,
+		SigningWorkers:                4,
+		TxGasLimitMarginPercent:       20,
+		TxReceiptTimeoutSeconds:       120,
+		TxReplaceAfterSeconds:         30,
+		TxGasBumpPercent:              20,
+
+		ResolveRewardsBatchGasSharePercent: 50,
 	}
 	if err := json.NewDecoder(f).Decode(cfg); err != nil {
 		return nil, fmt.Errorf("decode config: %w", err)
 	}
 
+	// The upper bound, the epoch duration, may come from the contract, so
+	// the daemon checks that one when it starts.
+	if cfg.DaemonRunOffsetSeconds < 0 {
+		return nil, fmt.Errorf("daemon_run_offset_seconds must not be negative, got %d", cfg.DaemonRunOffsetSeconds)
+	}
+	if cfg.UptimeSearchMaxAttempts < 1 {
+		return nil, fmt.Errorf("uptime_search_max_attempts must be at least 1, got %d", cfg.UptimeSearchMaxAttempts)
+	}
+
 	return cfg, nil
 }
+
+// Hash fingerprints the effective configuration, defaults included, so a
+// recorded run can be matched to the settings it ran with. Secrets (the
+// private key, the Slack webhook and the database URL, which may carry a
+// password) are left out, so the runs table holds no fingerprint of them,
+// and so is DryRun, which comes from the command line rather than the file.
+func (c *Config) Hash() string {
+	hashed := *c
+	hashed.PrivateKey = ""
+	hashed.SlackWebhookURL = ""
+	hashed.DatabaseURL = ""
+	hashed.DryRun = false
+
+	b, err := json.Marshal(hashed)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
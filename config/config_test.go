@@ -0,0 +1,32 @@
+package config
+
+import "testing"
+
+func TestHashIgnoresSecretsAndDryRun(t *testing.T) {
+	base := Config{
+		AggregatorURL:         "http://localhost:9090/aggregate-signatures",
+		StakingManagerAddress: "0x0000000000000000000000000000000000000001",
+		PrivateKey:            "key-a",
+		SlackWebhookURL:       "https://hooks.slack.com/a",
+		DatabaseURL:           "postgres://user:pass-a@db/uptime",
+		NetworkID:             1,
+	}
+	want := base.Hash()
+
+	changed := base
+	changed.PrivateKey = "key-b"
+	changed.SlackWebhookURL = "https://hooks.slack.com/b"
+	changed.DatabaseURL = "postgres://user:pass-b@db/uptime"
+	changed.DryRun = true
+	if got := changed.Hash(); got != want {
+		t.Errorf("hash changed with secrets or dry run: %s, want %s", got, want)
+	}
+	if base.PrivateKey != "key-a" || !changed.DryRun {
+		t.Errorf("Hash modified the config it was called on")
+	}
+
+	changed.NetworkID = 2
+	if got := changed.Hash(); got == want {
+		t.Errorf("hash did not change with network_id")
+	}
+}
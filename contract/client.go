@@ -1,92 +1,162 @@
 package contract
 
 import (
-	"encoding/hex"
+	"context"
 	"fmt"
-	"math/big"
 	"strings"
 
+	"uptime-service/chain"
 	"uptime-service/logging"
 
-	"github.com/ava-labs/avalanche-tooling-sdk-go/evm"
-	"github.com/ava-labs/avalanche-tooling-sdk-go/evm/contract"
-	"github.com/ava-labs/avalanche-tooling-sdk-go/key"
-	"github.com/ava-labs/avalanche-tooling-sdk-go/validatormanager"
 	"github.com/ava-labs/avalanchego/ids"
-	"github.com/ava-labs/avalanchego/utils/crypto/secp256k1"
 	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+	ethereum "github.com/ava-labs/libevm"
+	"github.com/ava-labs/libevm/accounts/abi"
 	"github.com/ava-labs/libevm/common"
-	"github.com/ava-labs/libevm/crypto"
+	"github.com/ava-labs/libevm/core/types"
+	"github.com/ava-labs/libevm/ethclient"
+	warpprecompile "github.com/ava-labs/subnet-evm/precompile/contracts/warp"
 )
 
 type ContractClient struct {
-	RPCURL                string
 	StakingManagerAddress string
-	WarpMessengerAddress  string
-	privateKey            *secp256k1.PrivateKey
-	publicAddress         string
+	sender                *chain.Sender
+	ethClient             *ethclient.Client
+	dryRun                bool
 }
 
-func NewContractClient(rpcURL, contractAddr, warpMessengerAddr, privateKeyHex string) (*ContractClient, error) {
-	pkHex := strings.TrimPrefix(privateKeyHex, "0x")
+// NewContractClient builds a client for the staking manager that sends its
+// transactions through sender, sharing its nonces with every other client
+// built on the same sender. With dryRun set, SubmitUptimeProof only
+// simulates the transaction.
+func NewContractClient(sender *chain.Sender, contractAddr string, dryRun bool) *ContractClient {
+	return &ContractClient{
+		StakingManagerAddress: contractAddr,
+		sender:                sender,
+		ethClient:             sender.Client(),
+		dryRun:                dryRun,
+	}
+}
 
-	raw, err := hex.DecodeString(pkHex)
+// Submission is what SubmitUptimeProof knows about the transaction it sent.
+// TxHash is zero if nothing was sent, as in dry runs; Receipt is nil unless
+// the transaction was mined.
+type Submission struct {
+	TxHash  common.Hash
+	Nonce   uint64
+	Receipt *types.Receipt
+}
+
+// SubmitUptimeProof sends a submitUptimeProof transaction carrying
+// signedMessage and waits for it to be mined. It succeeds once the
+// transaction has landed; a mined transaction that reverted returns a
+// *chain.RevertError, and one that is not mined in time returns
+// chain.ErrReceiptTimeout. A call that would revert fails at gas estimation,
+// before anything is sent. Either way, a rejected signed message wraps
+// ErrInvalidWarpMessage.
+func (c ContractClient) SubmitUptimeProof(ctx context.Context, validationID ids.ID, signedMessage *warp.Message) (Submission, error) {
+	logging.Infof("Submitting uptime proof for validation ID: %s", validationID.Hex())
+
+	signedWarpMsg, err := warp.ParseMessage(signedMessage.Bytes())
 	if err != nil {
-		return nil, fmt.Errorf("invalid hex private key: %w", err)
+		return Submission{}, fmt.Errorf("failed to parse signed warp message: %w", err)
 	}
 
-	secpPriv, err := secp256k1.ToPrivateKey(raw)
+	msg, err := c.uptimeProofCall(validationID, signedWarpMsg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse secp256k1 private key: %w", err)
+		return Submission{}, err
 	}
 
-	ecdsaPriv := secpPriv.ToECDSA()
-	pubAddr := crypto.PubkeyToAddress(ecdsaPriv.PublicKey).Hex()
-
-	return &ContractClient{
-		RPCURL:                rpcURL,
-		StakingManagerAddress: contractAddr,
-		WarpMessengerAddress:  warpMessengerAddr,
-		privateKey:            secpPriv,
-		publicAddress:         pubAddr,
-	}, nil
-}
+	if c.dryRun {
+		return Submission{}, c.simulateUptimeProof(ctx, validationID, msg)
+	}
 
-func (c ContractClient) SubmitUptimeProof(validationID ids.ID, signedMessage *warp.Message) error {
-	logging.Infof("Submitting uptime proof for validation ID: %s", validationID.Hex())
+	pending, err := c.sender.Send(ctx, chain.TxRequest{
+		To:         *msg.To,
+		Data:       msg.Data,
+		AccessList: msg.AccessList,
+	})
+	if err != nil {
+		return Submission{}, fmt.Errorf(
+			"failed to send tx to validator manager: %w",
+			chain.DecodeCallError(err, revertSignatures),
+		)
+	}
+	logging.Infof("sent uptime proof transaction %s (nonce %d), waiting for receipt", pending.Hash().Hex(), pending.Nonce())
 
-	signedWarpMsg, err := warp.ParseMessage(signedMessage.Bytes())
+	receipt, err := c.sender.Wait(ctx, pending, revertSignatures)
+	sub := Submission{TxHash: pending.Hash(), Nonce: pending.Nonce(), Receipt: receipt}
+	if receipt != nil {
+		// A replacement may be the version that got mined.
+		sub.TxHash = receipt.TxHash
+	}
 	if err != nil {
-		return fmt.Errorf("failed to parse signed warp message: %w", err)
+		return sub, err
 	}
 
-	softKey, err := key.NewSoft(key.WithPrivateKey(c.privateKey))
+	logging.Infof(
+		"SUCCESS: uptime proof transaction %s mined in block %s (gas used %d)",
+		receipt.TxHash.Hex(),
+		receipt.BlockNumber,
+		receipt.GasUsed,
+	)
+	return sub, nil
+}
+
+const submitUptimeProofABI = `[{"inputs":[{"internalType":"bytes32","name":"validationID","type":"bytes32"},{"internalType":"uint32","name":"messageIndex","type":"uint32"}],"name":"submitUptimeProof","outputs":[],"stateMutability":"nonpayable","type":"function"}]`
+
+// uptimeProofCall builds the submitUptimeProof call, carrying the signed warp
+// message in the access list where the warp precompile expects it.
+func (c ContractClient) uptimeProofCall(validationID ids.ID, signedWarpMsg *warp.Message) (ethereum.CallMsg, error) {
+	parsedABI, err := abi.JSON(strings.NewReader(submitUptimeProofABI))
 	if err != nil {
-		return fmt.Errorf("failed to initialize soft key from in-memory private key: %w", err)
+		return ethereum.CallMsg{}, fmt.Errorf("parse ABI: %w", err)
 	}
 
-	signer, err := evm.NewSigner(softKey.KeyChain())
+	data, err := parsedABI.Pack("submitUptimeProof", [32]byte(validationID), uint32(0))
 	if err != nil {
-		return fmt.Errorf("failed to create signer: %w", err)
+		return ethereum.CallMsg{}, fmt.Errorf("pack tx data: %w", err)
 	}
 
-	finalTx, _, err := contract.TxToMethodWithWarpMessage(
-		nil,
-		c.RPCURL,
-		signer,
-		common.HexToAddress(c.StakingManagerAddress),
-		signedWarpMsg,
-		big.NewInt(0),
-		"submit uptime proof",
-		validatormanager.ErrorSignatureToError,
-		"submitUptimeProof(bytes32,uint32)",
-		validationID,
-		uint32(0),
-	)
+	to := common.HexToAddress(c.StakingManagerAddress)
+	return ethereum.CallMsg{
+		From:       c.sender.From(),
+		To:         &to,
+		Data:       data,
+		AccessList: warpAccessList(signedWarpMsg.Bytes()),
+	}, nil
+}
+
+// simulateUptimeProof runs the submitUptimeProof call through eth_call and
+// gas estimation against the latest block. Nothing is broadcast.
+func (c ContractClient) simulateUptimeProof(ctx context.Context, validationID ids.ID, msg ethereum.CallMsg) error {
+	if _, err := c.ethClient.CallContract(ctx, msg, nil); err != nil {
+		return fmt.Errorf("simulate submit uptime proof: %w", chain.DecodeCallError(err, revertSignatures))
+	}
+	gas, err := c.ethClient.EstimateGas(ctx, msg)
 	if err != nil {
-		return fmt.Errorf("failed to send tx to validator manager: %w", err)
+		return fmt.Errorf("estimate gas: %w", err)
 	}
 
-	logging.Infof("SUCCESS: Submitted uptime proof transaction: %s", finalTx.Hash().Hex())
+	logging.Infof("DRY RUN: uptime proof for %s would succeed (estimated gas %d)", validationID.Hex(), gas)
 	return nil
 }
+
+// warpAccessList packs a signed warp message the way the warp precompile
+// reads it back as a predicate: the bytes followed by a 0xff delimiter,
+// zero-padded to a whole number of 32-byte storage keys under the precompile
+// address. client_test.go pins this to subnet-evm's predicate packing.
+func warpAccessList(msg []byte) types.AccessList {
+	padded := make([]byte, 0, len(msg)+common.HashLength)
+	padded = append(padded, msg...)
+	padded = append(padded, 0xff)
+	if rem := len(padded) % common.HashLength; rem != 0 {
+		padded = append(padded, make([]byte, common.HashLength-rem)...)
+	}
+
+	keys := make([]common.Hash, 0, len(padded)/common.HashLength)
+	for i := 0; i < len(padded); i += common.HashLength {
+		keys = append(keys, common.BytesToHash(padded[i:i+common.HashLength]))
+	}
+	return types.AccessList{{Address: warpprecompile.ContractAddress, StorageKeys: keys}}
+}
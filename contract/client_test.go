@@ -0,0 +1,42 @@
+package contract
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/ava-labs/libevm/common"
+	"github.com/ava-labs/subnet-evm/predicate"
+)
+
+// TestWarpAccessList pins the access list carrying a signed warp message to
+// subnet-evm's predicate packing, which is what the warp precompile unpacks.
+func TestWarpAccessList(t *testing.T) {
+	wantAddr := common.HexToAddress("0x0200000000000000000000000000000000000005")
+
+	for _, size := range []int{0, 1, 31, 32, 33, 64, 250} {
+		msg := bytes.Repeat([]byte{0xab}, size)
+
+		list := warpAccessList(msg)
+		if len(list) != 1 {
+			t.Fatalf("%d-byte message: got %d access list entries, want 1", size, len(list))
+		}
+		if list[0].Address != wantAddr {
+			t.Errorf("%d-byte message: address %s, want %s", size, list[0].Address, wantAddr)
+		}
+		if want := predicate.PreparePredicateStorageSlots(wantAddr, msg); !reflect.DeepEqual(list, want) {
+			t.Errorf("%d-byte message: access list %v, want %v", size, list, want)
+		}
+	}
+}
+
+// TestWarpAccessListLayout spells out the packing for a short message: the
+// bytes, a 0xff delimiter, then zeros up to the end of the 32-byte key.
+func TestWarpAccessListLayout(t *testing.T) {
+	list := warpAccessList([]byte{0x01, 0x02, 0x03})
+
+	want := common.HexToHash("0x010203ff00000000000000000000000000000000000000000000000000000000")
+	if keys := list[0].StorageKeys; len(keys) != 1 || keys[0] != want {
+		t.Fatalf("storage keys %v, want [%s]", keys, want)
+	}
+}
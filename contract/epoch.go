@@ -0,0 +1,66 @@
+package contract
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"uptime-service/epoch"
+
+	ethereum "github.com/ava-labs/libevm"
+	"github.com/ava-labs/libevm/accounts/abi"
+	"github.com/ava-labs/libevm/common"
+)
+
+// Outputs are declared as uint256 so decoding works whatever uintN width
+// the staking manager uses for them.
+const epochABI = `[
+	{"inputs":[],"name":"epochDuration","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},
+	{"inputs":[],"name":"startTime","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"}
+]`
+
+// EpochSchedule reads the epoch duration and start time from the staking
+// manager contract.
+func (c ContractClient) EpochSchedule(ctx context.Context) (epoch.Schedule, error) {
+	parsedABI, err := abi.JSON(strings.NewReader(epochABI))
+	if err != nil {
+		return epoch.Schedule{}, fmt.Errorf("parse ABI: %w", err)
+	}
+
+	duration, err := c.callUint(ctx, parsedABI, "epochDuration")
+	if err != nil {
+		return epoch.Schedule{}, err
+	}
+	start, err := c.callUint(ctx, parsedABI, "startTime")
+	if err != nil {
+		return epoch.Schedule{}, err
+	}
+
+	return epoch.NewSchedule(start.Int64(), duration.Int64())
+}
+
+// callUint calls a no-argument view method on the staking manager that
+// returns a single unsigned integer which must fit in an int64.
+func (c ContractClient) callUint(ctx context.Context, parsedABI abi.ABI, method string) (*big.Int, error) {
+	data, err := parsedABI.Pack(method)
+	if err != nil {
+		return nil, fmt.Errorf("pack %s: %w", method, err)
+	}
+
+	to := common.HexToAddress(c.StakingManagerAddress)
+	out, err := c.ethClient.CallContract(ctx, ethereum.CallMsg{To: &to, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("call %s: %w", method, err)
+	}
+
+	values, err := parsedABI.Unpack(method, out)
+	if err != nil {
+		return nil, fmt.Errorf("unpack %s: %w", method, err)
+	}
+	v, ok := values[0].(*big.Int)
+	if !ok || !v.IsInt64() {
+		return nil, fmt.Errorf("unexpected %s value: %v", method, values[0])
+	}
+	return v, nil
+}
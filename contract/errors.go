@@ -0,0 +1,22 @@
+package contract
+
+import (
+	"errors"
+	"maps"
+
+	"github.com/ava-labs/avalanche-tooling-sdk-go/validatormanager"
+)
+
+// ErrInvalidWarpMessage is wrapped by SubmitUptimeProof errors when the
+// staking manager rejects the signed message, usually because its signatures
+// no longer verify against the current validator set. Re-signing the same
+// uptime fixes it.
+var ErrInvalidWarpMessage = errors.New("invalid warp message")
+
+// revertSignatures is the validator manager's custom-error table with the
+// reverts this package's callers react to mapped to its own sentinels.
+var revertSignatures = func() map[string]error {
+	m := maps.Clone(validatormanager.ErrorSignatureToError)
+	m["InvalidWarpMessage()"] = ErrInvalidWarpMessage
+	return m
+}()
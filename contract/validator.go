@@ -0,0 +1,50 @@
+package contract
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ava-labs/avalanchego/ids"
+	ethereum "github.com/ava-labs/libevm"
+	"github.com/ava-labs/libevm/accounts/abi"
+	"github.com/ava-labs/libevm/common"
+)
+
+const stakingValidatorABI = `[{"inputs":[{"internalType":"bytes32","name":"validationID","type":"bytes32"}],"name":"getStakingValidator","outputs":[{"components":[{"internalType":"address","name":"owner","type":"address"},{"internalType":"uint16","name":"delegationFeeBips","type":"uint16"},{"internalType":"uint64","name":"minStakeDuration","type":"uint64"},{"internalType":"uint64","name":"uptimeSeconds","type":"uint64"}],"internalType":"struct PoSValidatorInfo","name":"","type":"tuple"}],"stateMutability":"view","type":"function"}]`
+
+// stakingValidatorInfo matches the tuple getStakingValidator returns, field
+// for field, so abi.ConvertType can copy the decoded value into it.
+type stakingValidatorInfo struct {
+	Owner             common.Address
+	DelegationFeeBips uint16
+	MinStakeDuration  uint64
+	UptimeSeconds     uint64
+}
+
+// GetValidatorUptime reads the uptime the staking manager currently holds
+// for validationID, i.e. the highest uptime proven so far.
+func (c ContractClient) GetValidatorUptime(ctx context.Context, validationID ids.ID) (uint64, error) {
+	parsedABI, err := abi.JSON(strings.NewReader(stakingValidatorABI))
+	if err != nil {
+		return 0, fmt.Errorf("parse ABI: %w", err)
+	}
+
+	data, err := parsedABI.Pack("getStakingValidator", [32]byte(validationID))
+	if err != nil {
+		return 0, fmt.Errorf("pack getStakingValidator: %w", err)
+	}
+
+	to := common.HexToAddress(c.StakingManagerAddress)
+	out, err := c.ethClient.CallContract(ctx, ethereum.CallMsg{To: &to, Data: data}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("call getStakingValidator: %w", err)
+	}
+
+	values, err := parsedABI.Unpack("getStakingValidator", out)
+	if err != nil {
+		return 0, fmt.Errorf("unpack getStakingValidator: %w", err)
+	}
+	info := *abi.ConvertType(values[0], new(stakingValidatorInfo)).(*stakingValidatorInfo)
+	return info.UptimeSeconds, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"uptime-service/config"
+	"uptime-service/db"
+	"uptime-service/logging"
+	"uptime-service/service"
+)
+
+// daemonStep is one command the daemon runs as part of a cycle. run receives
+// the epoch the cycle was scheduled for.
+type daemonStep struct {
+	name string
+	run  func(ctx context.Context, epochNum uint64) error
+}
+
+// runDaemon keeps the service alive and runs the full cycle
+// (generate-and-submit → submit-missing-uptime-proofs → resolve-rewards)
+// once per staking epoch, DaemonRunOffsetSeconds after the epoch starts.
+// Missing proofs are checked for the epoch the cycle runs in; rewards are
+// resolved for the epoch that just ended, matching the command defaults.
+//
+// Cycles run one after another on this goroutine, so a slow cycle delays the
+// next one instead of overlapping it. Cancelling ctx (SIGINT/SIGTERM) cancels
+// the step in progress, starts no further steps, and stops the daemon.
+func runDaemon(
+	ctx context.Context,
+	cfg *config.Config,
+	store db.Store,
+	uptimeSvc *service.UptimeService,
+) error {
+	sched, err := uptimeSvc.EpochSchedule(ctx)
+	if err != nil {
+		return fmt.Errorf("daemon needs an epoch schedule: %w", err)
+	}
+	offset := time.Duration(cfg.DaemonRunOffsetSeconds) * time.Second
+	// Outside [0, Duration) the run time would fall in another epoch than
+	// the one NextRun reports, and the cycle would work on the wrong epoch.
+	if offset < 0 || offset >= sched.Duration {
+		return fmt.Errorf(
+			"daemon_run_offset_seconds must be at least 0 and less than the epoch duration (%s), got %s",
+			sched.Duration,
+			offset,
+		)
+	}
+
+	steps := []daemonStep{
+		{"generate-and-submit", func(ctx context.Context, _ uint64) error {
+			return uptimeSvc.GenerateAndSubmitUptimeProofs(ctx)
+		}},
+		{"submit-missing-uptime-proofs", func(ctx context.Context, epochNum uint64) error {
+			return uptimeSvc.SubmitMissingUptimeProofs(ctx, []uint64{epochNum})
+		}},
+		{"resolve-rewards", func(ctx context.Context, epochNum uint64) error {
+			if epochNum == 0 {
+				return fmt.Errorf("no staking epoch has completed yet")
+			}
+			return uptimeSvc.ResolveRewards(ctx, epochNum-1)
+		}},
+	}
+
+	logging.Infof(
+		"daemon started: epochs of %s since %s, running %s into each epoch",
+		sched.Duration,
+		sched.Start.Format(time.RFC3339),
+		offset,
+	)
+
+	// lastEpoch is the epoch of the latest cycle, if ran. Scheduling starts
+	// after its run time, so a cycle run on start before that time is not
+	// repeated when the time comes.
+	var lastEpoch uint64
+	var ran bool
+	if cfg.DaemonRunOnStart {
+		lastEpoch, ran = sched.At(time.Now()), true
+		runLockedCycle(ctx, cfg, store, lastEpoch, steps)
+	}
+
+	for {
+		after := time.Now()
+		if lastRun := sched.StartOf(lastEpoch).Add(offset); ran && after.Before(lastRun) {
+			after = lastRun
+		}
+		next, epochNum := sched.NextRun(after, offset)
+		logging.Infof("next cycle for epoch %d at %s", epochNum, next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			logging.Info("shutdown signal received, daemon stopping")
+			return nil
+		case <-timer.C:
+		}
+
+		runLockedCycle(ctx, cfg, store, epochNum, steps)
+		lastEpoch, ran = epochNum, true
+
+		if ctx.Err() != nil {
+			logging.Info("shutdown signal received, daemon stopping")
+			return nil
+		}
+	}
+}
+
+// runLockedCycle runs a cycle under the run lock. If another daemon or a
+// manual command holds the lock, the cycle is skipped rather than queued:
+// with redundant daemons, whichever takes the lock first does the epoch's
+// work, and running it again afterwards would only repeat it.
+func runLockedCycle(
+	ctx context.Context,
+	cfg *config.Config,
+	store db.Store,
+	epochNum uint64,
+	steps []daemonStep,
+) {
+	release, err := acquireRunLock(ctx, cfg, store, "daemon", 0)
+	if err != nil {
+		logging.Infof("skipping daemon cycle for epoch %d: %v", epochNum, err)
+		return
+	}
+	defer release()
+
+	runDaemonCycle(ctx, epochNum, steps)
+}
+
+// runDaemonCycle runs every step in order. A failed step is logged and the
+// cycle moves on: one bad command must not take the daemon down or keep the
+// remaining steps from running.
+func runDaemonCycle(ctx context.Context, epochNum uint64, steps []daemonStep) {
+	cycleStart := time.Now()
+	logging.Infof("==== daemon cycle for epoch %d started ====", epochNum)
+
+	for _, step := range steps {
+		if ctx.Err() != nil {
+			logging.Infof("daemon is shutting down, skipping %s and later steps", step.name)
+			break
+		}
+
+		stepStart := time.Now()
+		logging.Infof("daemon step %s started", step.name)
+		if err := step.run(ctx, epochNum); err != nil {
+			logging.Errorf("daemon step %s failed after %s: %v", step.name, time.Since(stepStart), err)
+			continue
+		}
+		logging.Infof("daemon step %s finished in %s", step.name, time.Since(stepStart))
+	}
+
+	logging.Infof("==== daemon cycle for epoch %d finished in %s ====", epochNum, time.Since(cycleStart))
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"uptime-service/logging"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+)
+
+// ProofStatus is how a submission attempt recorded in the proof history
+// ended.
+type ProofStatus string
+
+const (
+	ProofSubmitted   ProofStatus = "submitted"   // mined successfully
+	ProofReverted    ProofStatus = "reverted"    // mined but reverted
+	ProofUnconfirmed ProofStatus = "unconfirmed" // sent, no receipt within the timeout
+	ProofFailed      ProofStatus = "failed"      // never made it on-chain
+)
+
+// ProofHistoryEntry is one row of uptime_proof_history: a signed uptime
+// proof and what happened when we tried to submit it. Epoch is NULL when the
+// epoch could not be determined at submission time; TxHash is empty when no
+// transaction was sent.
+type ProofHistoryEntry struct {
+	ID            int64
+	ValidationID  ids.ID
+	Epoch         sql.NullInt64
+	UptimeSeconds uint64
+	SignedMessage *warp.Message
+	TxHash        string
+	Status        ProofStatus
+	Error         string
+	SignedAt      time.Time
+	RecordedAt    time.Time
+}
+
+// RecordProofHistory appends entry to uptime_proof_history. Rows are never
+// updated or deleted; uptime_proofs remains the latest-proof view.
+func (s *sqlStore) RecordProofHistory(ctx context.Context, entry ProofHistoryEntry) error {
+	if s.dryRun {
+		logging.Infof(
+			"DRY RUN: skipping proof history write (%s) of uptime %d for %s",
+			entry.Status,
+			entry.UptimeSeconds,
+			entry.ValidationID.String(),
+		)
+		return nil
+	}
+
+	_, err := s.db.ExecContext(ctx, s.dialect.rebind(`
+		INSERT INTO uptime_proof_history
+			(validation_id, epoch, uptime_seconds, signed_message, tx_hash, status, error, signed_at, recorded_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`),
+		entry.ValidationID.String(),
+		entry.Epoch,
+		entry.UptimeSeconds,
+		entry.SignedMessage.Bytes(),
+		entry.TxHash,
+		string(entry.Status),
+		entry.Error,
+		entry.SignedAt.UTC(),
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("insert proof history: %w", err)
+	}
+	return nil
+}
+
+// GetProofHistory returns the recorded submissions for validationID, oldest
+// first. A valid epoch limits the result to that epoch.
+func (s *sqlStore) GetProofHistory(
+	ctx context.Context,
+	validationID ids.ID,
+	epoch sql.NullInt64,
+) ([]ProofHistoryEntry, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(`
+		SELECT id, validation_id, epoch, uptime_seconds, signed_message, tx_hash, status, error, signed_at, recorded_at
+		FROM uptime_proof_history
+		WHERE validation_id = $1 AND (CAST($2 AS BIGINT) IS NULL OR epoch = $2)
+		ORDER BY recorded_at, id
+	`), validationID.String(), epoch)
+	if err != nil {
+		return nil, fmt.Errorf("query proof history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []ProofHistoryEntry
+	for rows.Next() {
+		var entry ProofHistoryEntry
+		var validationIDStr, status string
+		var signedMessageBytes []byte
+
+		if err := rows.Scan(
+			&entry.ID,
+			&validationIDStr,
+			&entry.Epoch,
+			&entry.UptimeSeconds,
+			&signedMessageBytes,
+			&entry.TxHash,
+			&status,
+			&entry.Error,
+			&entry.SignedAt,
+			&entry.RecordedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan proof history: %w", err)
+		}
+
+		entry.ValidationID, err = ids.FromString(validationIDStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validation ID in db: %w", err)
+		}
+		entry.SignedMessage, err = warp.ParseMessage(signedMessageBytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid warp message in db: %w", err)
+		}
+		entry.Status = ProofStatus(status)
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate proof history: %w", err)
+	}
+	return entries, nil
+}
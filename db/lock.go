@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"uptime-service/logging"
+)
+
+// lockPollInterval is how often AcquireRunLock retries a held lock while
+// waiting for it.
+const lockPollInterval = 5 * time.Second
+
+// ErrLocked is returned by AcquireRunLock when another process holds the
+// lock and did not release it in time.
+var ErrLocked = errors.New("run lock is held by another process")
+
+// RunLock is a held run lock. Release is always safe to call once.
+type RunLock interface {
+	Release()
+}
+
+// RunLocker hands out run locks. Every Store is one, and so is a Migrator,
+// which can't open a Store while the schema is behind.
+//
+// AcquireRunLock takes the lock called name for networkID. holder describes
+// this process (command, host, pid) and is shown to anyone who finds the
+// lock taken. If the lock is held, it is retried for up to wait; after that,
+// or straight away if wait is 0, an error wrapping ErrLocked names the
+// current holder.
+type RunLocker interface {
+	AcquireRunLock(ctx context.Context, name string, networkID uint32, holder string, wait time.Duration) (RunLock, error)
+}
+
+// waitForLock calls try until it takes the lock called name, retrying for
+// up to wait while the lock is held. After that, or straight away if wait is
+// 0, it returns an error wrapping ErrLocked that includes describe's account
+// of the current holder.
+func waitForLock(
+	ctx context.Context,
+	name string,
+	wait time.Duration,
+	try func() (bool, error),
+	describe func() string,
+) error {
+	deadline := time.Now().Add(wait)
+	for {
+		acquired, err := try()
+		if err != nil {
+			return err
+		}
+		if acquired {
+			return nil
+		}
+
+		if !time.Now().Before(deadline) {
+			held := describe()
+			if wait > 0 {
+				return fmt.Errorf("%w: %s, gave up after waiting %s", ErrLocked, held, wait)
+			}
+			return fmt.Errorf("%w: %s", ErrLocked, held)
+		}
+
+		logging.Infof("run lock %s is held (%s), waiting", name, describe())
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("wait for run lock %s: %w", name, ctx.Err())
+		case <-time.After(min(lockPollInterval, time.Until(deadline))):
+		}
+	}
+}
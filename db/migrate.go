@@ -0,0 +1,403 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"uptime-service/logging"
+)
+
+// Migrations live in migrations/postgres/ and migrations/sqlite/ as
+// NNNN_name.up.sql / NNNN_name.down.sql pairs and are applied in version
+// order. Both directories hold the same versions, each in its own dialect.
+// A migration must never be edited once released; change the schema by
+// adding a new one to both.
+//
+//go:embed migrations/postgres/*.sql migrations/sqlite/*.sql
+var migrationFiles embed.FS
+
+var migrationFileRE = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// ErrSchemaBehind is returned by Open when the database has not
+// had every migration of this build applied.
+var ErrSchemaBehind = errors.New("database schema is behind")
+
+// ErrForceRequired is returned by Migrator.Down when asked to roll back the
+// first migration without force.
+var ErrForceRequired = errors.New("refusing to roll back the first migration without -force")
+
+const createSchemaMigrationsSQL = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+// Migration is one embedded schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is a migration together with whether, and when, it was
+// applied. Known is false for versions recorded in the database that this
+// build has no migration for, i.e. the database is ahead of the binary.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Known     bool
+	AppliedAt sql.NullTime
+}
+
+// loadMigrations parses the embedded migrations of d, ordered by version.
+func loadMigrations(d *dialect) ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, d.migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("read embedded migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		m := migrationFileRE.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected migration file name %q", entry.Name())
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %q", entry.Name())
+		}
+		body, err := migrationFiles.ReadFile(d.migrationsDir + "/" + entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// appliedMigrations returns the versions recorded in schema_migrations with
+// their names and application times. A database that has never been
+// migrated has no schema_migrations table and returns an empty map.
+func appliedMigrations(ctx context.Context, db *sql.DB, d *dialect) (map[int64]MigrationStatus, error) {
+	var exists bool
+	if err := db.QueryRowContext(ctx, d.schemaMigrationsExistSQL).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("check schema_migrations: %w", err)
+	}
+	applied := make(map[int64]MigrationStatus)
+	if !exists {
+		return applied, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT version, name, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var st MigrationStatus
+		if err := rows.Scan(&st.Version, &st.Name, &st.AppliedAt); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		applied[st.Version] = st
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate schema_migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// checkSchema returns ErrSchemaBehind unless every embedded migration has
+// been applied. A database ahead of this build (e.g. after rolling back the
+// binary) is only logged: the newer migrations are expected to be additive.
+func checkSchema(ctx context.Context, db *sql.DB, d *dialect) error {
+	migrations, err := loadMigrations(d)
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(ctx, db, d)
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	known := make(map[int64]bool, len(migrations))
+	for _, mig := range migrations {
+		known[mig.Version] = true
+		if _, ok := applied[mig.Version]; !ok {
+			pending = append(pending, fmt.Sprintf("%04d_%s", mig.Version, mig.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %d pending migration(s) %v; run `migrate up`", ErrSchemaBehind, len(pending), pending)
+	}
+	for version, st := range applied {
+		if !known[version] {
+			logging.Infof("database has migration %04d_%s that this build does not know about", version, st.Name)
+		}
+	}
+	return nil
+}
+
+// Migrator applies and rolls back the embedded migrations. It holds its own
+// connection so it can run against a database Open would refuse.
+type Migrator struct {
+	db         *sql.DB
+	dialect    *dialect
+	path       string // SQLite file, empty for Postgres
+	migrations []Migration
+	dryRun     bool
+}
+
+// NewMigrator connects to the database at dbURL, Postgres or SQLite as for
+// Open, for running migrations. With dryRun set, Up and Down only report
+// what they would do.
+func NewMigrator(ctx context.Context, dbURL string, dryRun bool) (*Migrator, error) {
+	d, dsn := postgresDialect, dbURL
+	path, isSQLite := sqlitePath(dbURL)
+	if isSQLite {
+		d, dsn = sqliteDialect, sqliteDSN(path)
+	}
+
+	migrations, err := loadMigrations(d)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping db: %w", err)
+	}
+
+	return &Migrator{db: db, dialect: d, path: path, migrations: migrations, dryRun: dryRun}, nil
+}
+
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+// AcquireRunLock takes the same run lock as a Store on this database.
+func (m *Migrator) AcquireRunLock(
+	ctx context.Context,
+	name string,
+	networkID uint32,
+	holder string,
+	wait time.Duration,
+) (RunLock, error) {
+	if m.path != "" {
+		return acquireFileLock(ctx, m.path, name, networkID, holder, wait)
+	}
+	return acquireAdvisoryLock(ctx, m.db, name, networkID, holder, wait)
+}
+
+// Status lists every embedded migration and whether it has been applied,
+// followed by any applied versions this build doesn't know.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := appliedMigrations(ctx, m.db, m.dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		st := MigrationStatus{Version: mig.Version, Name: mig.Name, Known: true}
+		if a, ok := applied[mig.Version]; ok {
+			st.AppliedAt = a.AppliedAt
+			delete(applied, mig.Version)
+		}
+		statuses = append(statuses, st)
+	}
+
+	unknown := make([]MigrationStatus, 0, len(applied))
+	for _, st := range applied {
+		unknown = append(unknown, st)
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].Version < unknown[j].Version })
+	return append(statuses, unknown...), nil
+}
+
+// Up applies every pending migration in version order and returns the ones
+// it applied. Each migration runs in its own transaction together with its
+// schema_migrations row, so a failure leaves the earlier ones in place and
+// nothing half-applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	if !m.dryRun {
+		if _, err := m.db.ExecContext(ctx, createSchemaMigrationsSQL); err != nil {
+			return nil, fmt.Errorf("create schema_migrations: %w", err)
+		}
+	}
+
+	var done []Migration
+	for _, mig := range m.migrations {
+		applied, err := m.apply(ctx, mig)
+		if err != nil {
+			return done, err
+		}
+		if applied {
+			done = append(done, mig)
+		}
+	}
+	return done, nil
+}
+
+// apply runs mig's up script unless it is already recorded. The table lock
+// (on SQLite, the write lock every transaction takes) serialises concurrent
+// `migrate up` runs: the second one waits, then sees the migration as
+// applied.
+func (m *Migrator) apply(ctx context.Context, mig Migration) (bool, error) {
+	if m.dryRun {
+		applied, err := appliedMigrations(ctx, m.db, m.dialect)
+		if err != nil {
+			return false, err
+		}
+		if _, ok := applied[mig.Version]; ok {
+			return false, nil
+		}
+		logging.Infof("DRY RUN: would apply migration %04d_%s", mig.Version, mig.Name)
+		return true, nil
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin migration %04d: %w", mig.Version, err)
+	}
+	defer tx.Rollback()
+
+	if err := m.lockTable(ctx, tx); err != nil {
+		return false, err
+	}
+	var exists bool
+	if err := tx.QueryRowContext(ctx,
+		m.dialect.rebind(`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`), mig.Version,
+	).Scan(&exists); err != nil {
+		return false, fmt.Errorf("check migration %04d: %w", mig.Version, err)
+	}
+	if exists {
+		return false, nil
+	}
+
+	logging.Infof("applying migration %04d_%s", mig.Version, mig.Name)
+	if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+		return false, fmt.Errorf("apply migration %04d_%s: %w", mig.Version, mig.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		m.dialect.rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`),
+		mig.Version, mig.Name, time.Now().UTC(),
+	); err != nil {
+		return false, fmt.Errorf("record migration %04d: %w", mig.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit migration %04d: %w", mig.Version, err)
+	}
+	return true, nil
+}
+
+// lockTable locks schema_migrations for the rest of tx, where the dialect
+// needs an explicit lock.
+func (m *Migrator) lockTable(ctx context.Context, tx *sql.Tx) error {
+	if m.dialect.lockMigrationsSQL == "" {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, m.dialect.lockMigrationsSQL); err != nil {
+		return fmt.Errorf("lock schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// Down rolls back the most recently applied migration and returns it, or
+// returns nil if nothing is applied. Only migrations this build knows can be
+// rolled back. Rolling back the first migration drops the stored proofs, so
+// it is refused with ErrForceRequired unless force is set.
+func (m *Migrator) Down(ctx context.Context, force bool) (*Migration, error) {
+	applied, err := appliedMigrations(ctx, m.db, m.dialect)
+	if err != nil {
+		return nil, err
+	}
+	var latest int64
+	for version := range applied {
+		latest = max(latest, version)
+	}
+	if latest == 0 {
+		return nil, nil
+	}
+
+	var mig *Migration
+	for i := range m.migrations {
+		if m.migrations[i].Version == latest {
+			mig = &m.migrations[i]
+		}
+	}
+	if mig == nil {
+		return nil, fmt.Errorf("latest applied migration %04d_%s is not part of this build", latest, applied[latest].Name)
+	}
+	if mig.Version == m.migrations[0].Version && !force {
+		return nil, fmt.Errorf("%w: rolling back %04d_%s drops every stored uptime proof", ErrForceRequired, mig.Version, mig.Name)
+	}
+
+	if m.dryRun {
+		logging.Infof("DRY RUN: would roll back migration %04d_%s", mig.Version, mig.Name)
+		return mig, nil
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin rollback of %04d: %w", mig.Version, err)
+	}
+	defer tx.Rollback()
+
+	if err := m.lockTable(ctx, tx); err != nil {
+		return nil, err
+	}
+	res, err := tx.ExecContext(ctx, m.dialect.rebind(`DELETE FROM schema_migrations WHERE version = $1`), mig.Version)
+	if err != nil {
+		return nil, fmt.Errorf("unrecord migration %04d: %w", mig.Version, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return nil, fmt.Errorf("migration %04d was rolled back concurrently", mig.Version)
+	}
+
+	logging.Infof("rolling back migration %04d_%s", mig.Version, mig.Name)
+	if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+		return nil, fmt.Errorf("roll back migration %04d_%s: %w", mig.Version, mig.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit rollback of %04d: %w", mig.Version, err)
+	}
+	return mig, nil
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS uptime_proofs (
+	validation_id TEXT PRIMARY KEY,
+	uptime_seconds BIGINT NOT NULL,
+	signed_message BYTEA NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS uptime_proof_history (
+	id BIGSERIAL PRIMARY KEY,
+	validation_id TEXT NOT NULL,
+	epoch BIGINT,
+	uptime_seconds BIGINT NOT NULL,
+	signed_message BYTEA NOT NULL,
+	tx_hash TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL,
+	error TEXT NOT NULL DEFAULT '',
+	signed_at TIMESTAMP NOT NULL,
+	recorded_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS uptime_proof_history_validation_id_idx
+	ON uptime_proof_history (validation_id, recorded_at);
+
+CREATE INDEX IF NOT EXISTS uptime_proof_history_epoch_idx
+	ON uptime_proof_history (epoch);
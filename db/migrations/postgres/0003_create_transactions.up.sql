@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS transactions (
+	id BIGSERIAL PRIMARY KEY,
+	kind TEXT NOT NULL,
+	tx_hash TEXT NOT NULL,
+	nonce BIGINT NOT NULL,
+	validation_id TEXT NOT NULL,
+	epoch BIGINT,
+	delegation_count INTEGER NOT NULL DEFAULT 0,
+	status TEXT NOT NULL,
+	block_number BIGINT,
+	gas_used BIGINT,
+	effective_gas_price NUMERIC(78, 0),
+	error TEXT NOT NULL DEFAULT '',
+	recorded_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS transactions_tx_hash_idx
+	ON transactions (tx_hash);
+
+CREATE INDEX IF NOT EXISTS transactions_validation_id_epoch_idx
+	ON transactions (validation_id, epoch);
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS runs (
+	id BIGSERIAL PRIMARY KEY,
+	command TEXT NOT NULL,
+	config_hash TEXT NOT NULL,
+	status TEXT NOT NULL,
+	error TEXT NOT NULL DEFAULT '',
+	started_at TIMESTAMP NOT NULL,
+	finished_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS run_validators (
+	run_id BIGINT NOT NULL REFERENCES runs (id) ON DELETE CASCADE,
+	validation_id TEXT NOT NULL,
+	outcome TEXT NOT NULL,
+	error TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (run_id, validation_id)
+);
+
+CREATE INDEX IF NOT EXISTS run_validators_validation_id_idx
+	ON run_validators (validation_id);
@@ -0,0 +1,23 @@
+-- effective_gas_price is TEXT rather than NUMERIC: SQLite would store a
+-- 78-digit NUMERIC as a float and lose precision.
+CREATE TABLE IF NOT EXISTS transactions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind TEXT NOT NULL,
+	tx_hash TEXT NOT NULL,
+	nonce BIGINT NOT NULL,
+	validation_id TEXT NOT NULL,
+	epoch BIGINT,
+	delegation_count INTEGER NOT NULL DEFAULT 0,
+	status TEXT NOT NULL,
+	block_number BIGINT,
+	gas_used BIGINT,
+	effective_gas_price TEXT,
+	error TEXT NOT NULL DEFAULT '',
+	recorded_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS transactions_tx_hash_idx
+	ON transactions (tx_hash);
+
+CREATE INDEX IF NOT EXISTS transactions_validation_id_epoch_idx
+	ON transactions (validation_id, epoch);
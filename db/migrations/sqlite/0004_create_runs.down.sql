@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS run_validators;
+DROP TABLE IF EXISTS runs;
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"uptime-service/logging"
+
+	_ "github.com/lib/pq"
+)
+
+// PostgresStore is the Store for a Postgres database. Its run lock is a
+// session-level advisory lock, so it is shared by every host using the
+// database.
+type PostgresStore struct {
+	sqlStore
+}
+
+// NewPostgresStore opens the Postgres database at dbURL as a Store.
+func NewPostgresStore(ctx context.Context, dbURL string, dryRun bool) (*PostgresStore, error) {
+	s, err := openSQL(ctx, postgresDialect, dbURL, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	logging.Info("connected to database and verified schema")
+
+	return &PostgresStore{sqlStore: s}, nil
+}
+
+// advisoryLock is a held Postgres session-level advisory lock. It lives on
+// its own connection, so it is released when Release is called or, if the
+// process dies, when Postgres notices the session is gone.
+type advisoryLock struct {
+	conn *sql.Conn
+	key  int64
+	name string
+}
+
+// runLockKey maps a lock name and network to the bigint advisory lock key.
+func runLockKey(name string, networkID uint32) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "uptime-service/%s/%d", name, networkID)
+	return int64(h.Sum64())
+}
+
+// AcquireRunLock takes an advisory lock keyed on name and networkID.
+func (s *PostgresStore) AcquireRunLock(
+	ctx context.Context,
+	name string,
+	networkID uint32,
+	holder string,
+	wait time.Duration,
+) (RunLock, error) {
+	return acquireAdvisoryLock(ctx, s.db, name, networkID, holder, wait)
+}
+
+// acquireAdvisoryLock takes the advisory lock on a connection of db; see
+// PostgresStore.AcquireRunLock.
+func acquireAdvisoryLock(
+	ctx context.Context,
+	db *sql.DB,
+	name string,
+	networkID uint32,
+	holder string,
+	wait time.Duration,
+) (RunLock, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get lock connection: %w", err)
+	}
+	lock := &advisoryLock{conn: conn, key: runLockKey(name, networkID), name: name}
+
+	// application_name is how other processes learn who holds the lock.
+	if _, err := conn.ExecContext(ctx, `SELECT set_config('application_name', $1, false)`, holder); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("set application name: %w", err)
+	}
+
+	err = waitForLock(ctx, name, wait, func() (bool, error) {
+		var acquired bool
+		if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, lock.key).Scan(&acquired); err != nil {
+			return false, fmt.Errorf("try advisory lock %s: %w", name, err)
+		}
+		return acquired, nil
+	}, func() string {
+		return lock.describeHolder(ctx)
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	logging.Infof("acquired run lock %s for network %d", name, networkID)
+	return lock, nil
+}
+
+// describeHolder names the session holding l's lock, as far as
+// pg_stat_activity tells. Failing to find out is not an error.
+func (l *advisoryLock) describeHolder(ctx context.Context) string {
+	var app string
+	var since time.Time
+	err := l.conn.QueryRowContext(ctx, `
+		SELECT a.application_name, a.backend_start
+		FROM pg_locks l
+		JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.locktype = 'advisory' AND l.granted
+			AND l.classid::bigint = $1 AND l.objid::bigint = $2 AND l.objsubid = 1
+		LIMIT 1
+	`, int64(uint32(uint64(l.key)>>32)), int64(uint32(l.key))).Scan(&app, &since)
+	if err != nil || app == "" {
+		return "holder unknown"
+	}
+	return fmt.Sprintf("held by %q, connected since %s", app, since.UTC().Format(time.RFC3339))
+}
+
+// Release unlocks l and returns its connection. If the unlock fails the
+// connection is discarded instead, which ends the session and so drops the
+// lock anyway.
+func (l *advisoryLock) Release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := l.conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1), set_config('application_name', '', false)`, l.key); err != nil {
+		logging.Errorf("release run lock %s: %v; dropping its connection", l.name, err)
+		_ = l.conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+	if err := l.conn.Close(); err != nil && !errors.Is(err, sql.ErrConnDone) {
+		logging.Errorf("close run lock connection: %v", err)
+	}
+}
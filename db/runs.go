@@ -0,0 +1,189 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"uptime-service/logging"
+)
+
+// ErrRunNotFound is returned by GetRun for an unknown run ID.
+var ErrRunNotFound = errors.New("run not found")
+
+// RunStatus is how a recorded command run ended.
+type RunStatus string
+
+const (
+	RunRunning     RunStatus = "running"     // started, not finished (or the process died)
+	RunSucceeded   RunStatus = "succeeded"   // every validator handled without error
+	RunPartial     RunStatus = "partial"     // finished, but some validators failed
+	RunFailed      RunStatus = "failed"      // the command itself returned an error
+	RunInterrupted RunStatus = "interrupted" // cancelled by a signal or -timeout
+)
+
+// Run is one row of the runs table: a single invocation of a command,
+// including each step of a daemon cycle.
+type Run struct {
+	ID         int64
+	Command    string
+	ConfigHash string
+	Status     RunStatus
+	Error      string
+	StartedAt  time.Time
+	FinishedAt sql.NullTime
+}
+
+// RunValidator is what a run did for one validator. Error is empty unless
+// that validator failed.
+type RunValidator struct {
+	ValidationID string
+	Outcome      string
+	Error        string
+}
+
+// StartRun records a run of command as running and returns its ID. Dry runs
+// are not recorded and get ID 0.
+func (s *sqlStore) StartRun(ctx context.Context, command, configHash string) (int64, error) {
+	if s.dryRun {
+		logging.Infof("DRY RUN: not recording this %s run", command)
+		return 0, nil
+	}
+
+	var id int64
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`
+		INSERT INTO runs (command, config_hash, status, started_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`), command, configHash, string(RunRunning), time.Now().UTC()).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("insert run: %w", err)
+	}
+	return id, nil
+}
+
+// FinishRun sets the final status of run id and stores its per-validator
+// outcomes, all in one transaction.
+func (s *sqlStore) FinishRun(
+	ctx context.Context,
+	id int64,
+	status RunStatus,
+	runErr string,
+	validators []RunValidator,
+) error {
+	if s.dryRun || id == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, s.dialect.rebind(`
+		UPDATE runs SET status = $2, error = $3, finished_at = $4 WHERE id = $1
+	`), id, string(status), runErr, time.Now().UTC()); err != nil {
+		return fmt.Errorf("update run %d: %w", id, err)
+	}
+
+	for _, v := range validators {
+		if _, err := tx.ExecContext(ctx, s.dialect.rebind(`
+			INSERT INTO run_validators (run_id, validation_id, outcome, error)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (run_id, validation_id)
+			DO UPDATE SET outcome = EXCLUDED.outcome, error = EXCLUDED.error
+		`), id, v.ValidationID, v.Outcome, v.Error); err != nil {
+			return fmt.Errorf("insert outcome of %s for run %d: %w", v.ValidationID, id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit run %d: %w", id, err)
+	}
+	return nil
+}
+
+// ListRuns returns the most recent runs, newest first.
+func (s *sqlStore) ListRuns(ctx context.Context, limit int) ([]Run, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(`
+		SELECT id, command, config_hash, status, error, started_at, finished_at
+		FROM runs
+		ORDER BY id DESC
+		LIMIT $1
+	`), limit)
+	if err != nil {
+		return nil, fmt.Errorf("query runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		var run Run
+		var status string
+		if err := rows.Scan(
+			&run.ID,
+			&run.Command,
+			&run.ConfigHash,
+			&status,
+			&run.Error,
+			&run.StartedAt,
+			&run.FinishedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan run: %w", err)
+		}
+		run.Status = RunStatus(status)
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate runs: %w", err)
+	}
+	return runs, nil
+}
+
+// GetRun returns run id and its per-validator outcomes, ordered by outcome
+// and validation ID.
+func (s *sqlStore) GetRun(ctx context.Context, id int64) (Run, []RunValidator, error) {
+	run := Run{ID: id}
+	var status string
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`
+		SELECT command, config_hash, status, error, started_at, finished_at
+		FROM runs
+		WHERE id = $1
+	`), id).Scan(&run.Command, &run.ConfigHash, &status, &run.Error, &run.StartedAt, &run.FinishedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Run{}, nil, fmt.Errorf("%w: %d", ErrRunNotFound, id)
+	}
+	if err != nil {
+		return Run{}, nil, fmt.Errorf("query run %d: %w", id, err)
+	}
+	run.Status = RunStatus(status)
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(`
+		SELECT validation_id, outcome, error
+		FROM run_validators
+		WHERE run_id = $1
+		ORDER BY outcome, validation_id
+	`), id)
+	if err != nil {
+		return Run{}, nil, fmt.Errorf("query outcomes of run %d: %w", id, err)
+	}
+	defer rows.Close()
+
+	var validators []RunValidator
+	for rows.Next() {
+		var v RunValidator
+		if err := rows.Scan(&v.ValidationID, &v.Outcome, &v.Error); err != nil {
+			return Run{}, nil, fmt.Errorf("scan run outcome: %w", err)
+		}
+		validators = append(validators, v)
+	}
+
+	if err := rows.Err(); err != nil {
+		return Run{}, nil, fmt.Errorf("iterate outcomes of run %d: %w", id, err)
+	}
+	return run, validators, nil
+}
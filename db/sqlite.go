@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"uptime-service/logging"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLiteStore is the Store for a local SQLite file, for running the service
+// without a Postgres server. Its run lock is a file lock next to the
+// database, so it only excludes processes on the same host, which are the
+// only ones that can share the file anyway.
+type SQLiteStore struct {
+	sqlStore
+	path string
+}
+
+// sqliteDSN opens path in WAL mode, so reads don't block on a writer, with
+// every transaction taking the write lock up front (BEGIN IMMEDIATE). A
+// writer waits up to 5s for another one instead of failing with "database
+// is locked".
+func sqliteDSN(path string) string {
+	return path + "?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate&_foreign_keys=on"
+}
+
+// NewSQLiteStore opens the SQLite database at path as a Store.
+func NewSQLiteStore(ctx context.Context, path string, dryRun bool) (*SQLiteStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("open db: empty SQLite path")
+	}
+
+	s, err := openSQL(ctx, sqliteDialect, sqliteDSN(path), dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	logging.Infof("opened SQLite database %s and verified schema", path)
+
+	return &SQLiteStore{sqlStore: s, path: path}, nil
+}
+
+// fileLock is a held flock on a lock file. The kernel drops it when the
+// file is closed, including when the process dies.
+type fileLock struct {
+	f    *os.File
+	name string
+}
+
+// AcquireRunLock flocks PATH.NAME-NETWORK.lock beside the database.
+func (s *SQLiteStore) AcquireRunLock(
+	ctx context.Context,
+	name string,
+	networkID uint32,
+	holder string,
+	wait time.Duration,
+) (RunLock, error) {
+	return acquireFileLock(ctx, s.path, name, networkID, holder, wait)
+}
+
+// acquireFileLock takes the file lock beside the database at dbPath; see
+// SQLiteStore.AcquireRunLock.
+func acquireFileLock(
+	ctx context.Context,
+	dbPath string,
+	name string,
+	networkID uint32,
+	holder string,
+	wait time.Duration,
+) (RunLock, error) {
+	lockPath := fmt.Sprintf("%s.%s-%d.lock", dbPath, name, networkID)
+	f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+
+	err = waitForLock(ctx, name, wait, func() (bool, error) {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("flock %s: %w", lockPath, err)
+		}
+		return true, nil
+	}, func() string {
+		return describeLockFile(lockPath)
+	})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if err := f.Truncate(0); err == nil {
+		_, err = f.WriteAt(fmt.Appendf(nil, "%s\n%s\n", holder, time.Now().UTC().Format(time.RFC3339)), 0)
+		if err != nil {
+			logging.Errorf("write holder to %s: %v", lockPath, err)
+		}
+	}
+
+	logging.Infof("acquired run lock %s for network %d", name, networkID)
+	return &fileLock{f: f, name: name}, nil
+}
+
+// describeLockFile names the holder recorded in a lock file. Failing to
+// find out is not an error.
+func describeLockFile(lockPath string) string {
+	content, err := os.ReadFile(lockPath)
+	if err != nil {
+		return "holder unknown"
+	}
+	holder, since, ok := strings.Cut(strings.TrimSpace(string(content)), "\n")
+	if !ok || holder == "" {
+		return "holder unknown"
+	}
+	return fmt.Sprintf("held by %q, since %s", holder, since)
+}
+
+// Release clears the holder from the lock file and unlocks it.
+func (l *fileLock) Release() {
+	_ = l.f.Truncate(0)
+	if err := syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN); err != nil {
+		logging.Errorf("release run lock %s: %v; closing the file drops it anyway", l.name, err)
+	}
+	if err := l.f.Close(); err != nil {
+		logging.Errorf("close run lock file: %v", err)
+	}
+}
@@ -0,0 +1,264 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+)
+
+// newTestSQLiteStore migrates a fresh SQLite database in a temporary
+// directory and opens it.
+func newTestSQLiteStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "uptime.db")
+
+	m, err := NewMigrator(ctx, "sqlite:"+path, false)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("close migrator: %v", err)
+	}
+
+	s, err := NewSQLiteStore(ctx, path, false)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// testMessage builds a warp message with an empty signature whose payload
+// encodes uptime, so stored messages can be told apart.
+func testMessage(t *testing.T, uptime uint64) *warp.Message {
+	t.Helper()
+	unsigned, err := warp.NewUnsignedMessage(1, ids.Empty, []byte{byte(uptime >> 8), byte(uptime)})
+	if err != nil {
+		t.Fatalf("NewUnsignedMessage: %v", err)
+	}
+	msg, err := warp.NewMessage(unsigned, &warp.BitSetSignature{})
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	return msg
+}
+
+func TestStoreUptimeProofNeverLowers(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSQLiteStore(t)
+	id := ids.GenerateTestID()
+
+	for _, uptime := range []uint64{100, 200} {
+		if err := s.StoreUptimeProof(ctx, id, uptime, testMessage(t, uptime)); err != nil {
+			t.Fatalf("store uptime %d: %v", uptime, err)
+		}
+	}
+
+	err := s.StoreUptimeProof(ctx, id, 150, testMessage(t, 150))
+	var refresh *RefreshRequiredError
+	if !errors.As(err, &refresh) {
+		t.Fatalf("storing a lower uptime: got %v, want a *RefreshRequiredError", err)
+	}
+	if refresh.StoredUptime != 200 {
+		t.Errorf("RefreshRequiredError.StoredUptime = %d, want 200", refresh.StoredUptime)
+	}
+
+	proofs, err := s.GetAllUptimeProofs(ctx)
+	if err != nil {
+		t.Fatalf("GetAllUptimeProofs: %v", err)
+	}
+	got, ok := proofs[id.String()]
+	if !ok {
+		t.Fatalf("no proof stored for %s", id)
+	}
+	if got.UptimeSeconds != 200 {
+		t.Errorf("stored uptime = %d, want 200", got.UptimeSeconds)
+	}
+	if !bytes.Equal(got.SignedMessage.Bytes(), testMessage(t, 200).Bytes()) {
+		t.Errorf("stored message is not the one signed for 200")
+	}
+}
+
+// TestImportUptimeProofsRoundTrip exports the proofs of one store, as
+// `proofs export` reads them, and imports them into another that already
+// holds some of the validators.
+func TestImportUptimeProofsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	src := newTestSQLiteStore(t)
+	dst := newTestSQLiteStore(t)
+
+	higher, equal, fresh := ids.GenerateTestID(), ids.GenerateTestID(), ids.GenerateTestID()
+	for id, uptime := range map[ids.ID]uint64{higher: 100, equal: 200, fresh: 300} {
+		if err := src.StoreUptimeProof(ctx, id, uptime, testMessage(t, uptime)); err != nil {
+			t.Fatalf("store source proof: %v", err)
+		}
+	}
+	if err := dst.StoreUptimeProof(ctx, higher, 500, testMessage(t, 500)); err != nil {
+		t.Fatalf("seed higher proof: %v", err)
+	}
+	if err := dst.StoreUptimeProof(ctx, equal, 200, testMessage(t, 999)); err != nil {
+		t.Fatalf("seed equal proof: %v", err)
+	}
+
+	exported, err := src.GetAllUptimeProofs(ctx)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	var proofs []UptimeProof
+	for _, proof := range exported {
+		proofs = append(proofs, proof)
+	}
+
+	stored, kept, err := dst.ImportUptimeProofs(ctx, proofs)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if stored != 2 || kept != 1 {
+		t.Errorf("import stored %d and kept %d, want 2 and 1", stored, kept)
+	}
+
+	got, err := dst.GetAllUptimeProofs(ctx)
+	if err != nil {
+		t.Fatalf("GetAllUptimeProofs: %v", err)
+	}
+	for _, want := range []struct {
+		name   string
+		id     ids.ID
+		uptime uint64
+		msg    *warp.Message
+	}{
+		{"higher stored uptime", higher, 500, testMessage(t, 500)},
+		{"equal uptime", equal, 200, testMessage(t, 200)}, // message refreshed, as by StoreUptimeProof
+		{"new validator", fresh, 300, testMessage(t, 300)},
+	} {
+		proof := got[want.id.String()]
+		if proof.UptimeSeconds != want.uptime {
+			t.Errorf("%s: stored uptime = %d, want %d", want.name, proof.UptimeSeconds, want.uptime)
+		}
+		if proof.SignedMessage == nil || !bytes.Equal(proof.SignedMessage.Bytes(), want.msg.Bytes()) {
+			t.Errorf("%s: stored message is not the expected one", want.name)
+		}
+	}
+
+	// A record the database rejects, here an uptime beyond BIGINT, fails
+	// the whole import, including the good record before it.
+	good := ids.GenerateTestID()
+	_, _, err = dst.ImportUptimeProofs(ctx, []UptimeProof{
+		{ValidationID: good, UptimeSeconds: 10, SignedMessage: testMessage(t, 10)},
+		{ValidationID: ids.GenerateTestID(), UptimeSeconds: 1 << 63, SignedMessage: testMessage(t, 11)},
+	})
+	if err == nil {
+		t.Fatalf("import with a bad record succeeded")
+	}
+	got, err = dst.GetAllUptimeProofs(ctx)
+	if err != nil {
+		t.Fatalf("GetAllUptimeProofs: %v", err)
+	}
+	if _, ok := got[good.String()]; ok || len(got) != 3 {
+		t.Errorf("failed import left %d proofs, want the 3 from before", len(got))
+	}
+}
+
+// TestStoreUptimeProofConcurrentWriters races two stores on the same file,
+// as two processes would, each writing a different uptime for the same
+// validator. Whichever commits first, the higher uptime must end up stored.
+func TestStoreUptimeProofConcurrentWriters(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSQLiteStore(t)
+	other, err := NewSQLiteStore(ctx, s.path, false)
+	if err != nil {
+		t.Fatalf("open second store: %v", err)
+	}
+	defer other.Close()
+
+	const rounds = 20
+	for round := range rounds {
+		id := ids.GenerateTestID()
+		start := make(chan struct{})
+		errs := make(chan error, 2)
+		var wg sync.WaitGroup
+		for _, w := range []struct {
+			store  *SQLiteStore
+			uptime uint64
+			msg    *warp.Message
+		}{{s, 100, testMessage(t, 100)}, {other, 200, testMessage(t, 200)}} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				err := w.store.StoreUptimeProof(ctx, id, w.uptime, w.msg)
+				var refresh *RefreshRequiredError
+				if errors.As(err, &refresh) && w.uptime < refresh.StoredUptime {
+					err = nil // the lower writer lost the race, as it should
+				}
+				errs <- err
+			}()
+		}
+		close(start)
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatalf("round %d: %v", round, err)
+			}
+		}
+
+		proofs, err := s.GetAllUptimeProofs(ctx)
+		if err != nil {
+			t.Fatalf("GetAllUptimeProofs: %v", err)
+		}
+		if got := proofs[id.String()].UptimeSeconds; got != 200 {
+			t.Fatalf("round %d: stored uptime = %d, want 200", round, got)
+		}
+	}
+}
+
+func TestSQLiteRunLock(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSQLiteStore(t)
+
+	lock, err := s.AcquireRunLock(ctx, "submit", 1, "first holder", 0)
+	if err != nil {
+		t.Fatalf("first AcquireRunLock: %v", err)
+	}
+
+	// A second store on the same file stands in for another process: flocks
+	// on separate opens of the file exclude each other.
+	other, err := NewSQLiteStore(ctx, s.path, false)
+	if err != nil {
+		t.Fatalf("open second store: %v", err)
+	}
+	defer other.Close()
+
+	_, err = other.AcquireRunLock(ctx, "submit", 1, "second holder", 0)
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("AcquireRunLock while held: got %v, want ErrLocked", err)
+	}
+	if !strings.Contains(err.Error(), "first holder") {
+		t.Errorf("ErrLocked does not name the holder: %v", err)
+	}
+
+	otherNetwork, err := other.AcquireRunLock(ctx, "submit", 2, "second holder", 0)
+	if err != nil {
+		t.Fatalf("lock for another network: %v", err)
+	}
+	otherNetwork.Release()
+
+	lock.Release()
+	again, err := other.AcquireRunLock(ctx, "submit", 1, "second holder", 0)
+	if err != nil {
+		t.Fatalf("AcquireRunLock after release: %v", err)
+	}
+	again.Release()
+}
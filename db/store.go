@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+)
+
+// Store is everything the service persists: the latest proof per validator,
+// the proof history, sent transactions and command runs, plus the run lock.
+// PostgresStore and SQLiteStore implement it; Open picks one from the
+// database URL.
+//
+// Opening a Store checks that every migration has been applied and fails
+// with an error wrapping ErrSchemaBehind if not; the schema is changed only
+// by `migrate up`. A Store opened with dryRun reads as usual but skips
+// writes. Timestamps are written by the Store, in UTC, rather than left to
+// column defaults, which follow the server's time zone on Postgres.
+type Store interface {
+	StoreUptimeProof(ctx context.Context, validationID ids.ID, uptimeSeconds uint64, signedMessage *warp.Message) error
+	GetAllUptimeProofs(ctx context.Context) (map[string]UptimeProof, error)
+	ImportUptimeProofs(ctx context.Context, proofs []UptimeProof) (stored, kept int, err error)
+
+	RecordProofHistory(ctx context.Context, entry ProofHistoryEntry) error
+	GetProofHistory(ctx context.Context, validationID ids.ID, epoch sql.NullInt64) ([]ProofHistoryEntry, error)
+
+	RecordTransaction(ctx context.Context, rec TxRecord) error
+
+	StartRun(ctx context.Context, command, configHash string) (int64, error)
+	FinishRun(ctx context.Context, id int64, status RunStatus, runErr string, validators []RunValidator) error
+	ListRuns(ctx context.Context, limit int) ([]Run, error)
+	GetRun(ctx context.Context, id int64) (Run, []RunValidator, error)
+
+	RunLocker
+
+	Close() error
+}
+
+// Open connects to the database at dbURL: a SQLite file for sqlite:PATH
+// (or sqlite://PATH), Postgres for anything else.
+func Open(ctx context.Context, dbURL string, dryRun bool) (Store, error) {
+	if path, ok := sqlitePath(dbURL); ok {
+		return NewSQLiteStore(ctx, path, dryRun)
+	}
+	return NewPostgresStore(ctx, dbURL, dryRun)
+}
+
+// sqlitePath returns the file path of a sqlite: database URL.
+func sqlitePath(dbURL string) (string, bool) {
+	if path, ok := strings.CutPrefix(dbURL, "sqlite://"); ok {
+		return path, true
+	}
+	return strings.CutPrefix(dbURL, "sqlite:")
+}
+
+// dialect holds what differs between the SQL backends. Queries are written
+// once, in Postgres syntax with $N placeholders, and rebound per dialect.
+type dialect struct {
+	driver        string
+	migrationsDir string
+
+	// numberedParams rewrites $N placeholders as ?N. SQLite would accept
+	// $1 too, but as a named parameter bound in order of appearance rather
+	// than by number.
+	numberedParams bool
+
+	// forUpdate is appended to the read of a row that is about to be
+	// updated in the same transaction.
+	forUpdate string
+
+	schemaMigrationsExistSQL string
+
+	// lockMigrationsSQL serialises concurrent migration runs; empty when
+	// beginning a transaction already does.
+	lockMigrationsSQL string
+}
+
+var postgresDialect = &dialect{
+	driver:                   "postgres",
+	migrationsDir:            "migrations/postgres",
+	forUpdate:                " FOR UPDATE",
+	schemaMigrationsExistSQL: `SELECT to_regclass('schema_migrations') IS NOT NULL`,
+	lockMigrationsSQL:        `LOCK TABLE schema_migrations IN EXCLUSIVE MODE`,
+}
+
+// SQLite transactions are opened with BEGIN IMMEDIATE (see NewSQLiteStore),
+// which takes the database write lock up front; that both replaces
+// FOR UPDATE and serialises migrations.
+var sqliteDialect = &dialect{
+	driver:                   "sqlite3",
+	migrationsDir:            "migrations/sqlite",
+	numberedParams:           true,
+	schemaMigrationsExistSQL: `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')`,
+}
+
+var placeholderRE = regexp.MustCompile(`\$(\d+)`)
+
+// rebind adapts query's placeholders to d.
+func (d *dialect) rebind(query string) string {
+	if !d.numberedParams {
+		return query
+	}
+	return placeholderRE.ReplaceAllString(query, "?$1")
+}
+
+// sqlStore implements Store on top of database/sql. The backends embed it
+// and add what the dialect can't express, i.e. the run lock.
+type sqlStore struct {
+	db      *sql.DB
+	dialect *dialect
+	dryRun  bool
+}
+
+// openSQL connects with d's driver and checks the schema, as for Store.
+func openSQL(ctx context.Context, d *dialect, dsn string, dryRun bool) (sqlStore, error) {
+	db, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return sqlStore{}, fmt.Errorf("open db: %w", err)
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return sqlStore{}, fmt.Errorf("ping db: %w", err)
+	}
+
+	if err := checkSchema(ctx, db, d); err != nil {
+		db.Close()
+		return sqlStore{}, err
+	}
+
+	return sqlStore{db: db, dialect: d, dryRun: dryRun}, nil
+}
+
+func (s *sqlStore) Close() error {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"uptime-service/logging"
+
+	"github.com/ava-labs/avalanchego/ids"
+)
+
+// TxKind says what a recorded transaction was for.
+type TxKind string
+
+const (
+	TxUptimeProof    TxKind = "uptime_proof"    // submitUptimeProof
+	TxResolveRewards TxKind = "resolve_rewards" // one resolveRewards batch
+)
+
+// TxStatus is how a recorded transaction ended.
+type TxStatus string
+
+const (
+	TxMined       TxStatus = "mined"       // mined successfully
+	TxReverted    TxStatus = "reverted"    // mined but reverted
+	TxUnconfirmed TxStatus = "unconfirmed" // no receipt before we stopped waiting
+)
+
+// TxRecord is one row of the transactions table: a transaction the service
+// sent and, once mined, what its receipt says. BlockNumber, GasUsed and
+// EffectiveGasPrice (in wei, as a decimal string) are NULL for unconfirmed
+// transactions; Epoch is NULL when it could not be determined.
+type TxRecord struct {
+	Kind              TxKind
+	TxHash            string
+	Nonce             uint64
+	ValidationID      ids.ID
+	Epoch             sql.NullInt64
+	DelegationCount   int // resolve_rewards only
+	Status            TxStatus
+	BlockNumber       sql.NullInt64
+	GasUsed           sql.NullInt64
+	EffectiveGasPrice sql.NullString
+	Error             string
+}
+
+// RecordTransaction appends rec to the transactions table.
+func (s *sqlStore) RecordTransaction(ctx context.Context, rec TxRecord) error {
+	if s.dryRun {
+		logging.Infof("DRY RUN: skipping %s transaction record for %s", rec.Kind, rec.ValidationID.String())
+		return nil
+	}
+
+	_, err := s.db.ExecContext(ctx, s.dialect.rebind(`
+		INSERT INTO transactions
+			(kind, tx_hash, nonce, validation_id, epoch, delegation_count, status,
+			 block_number, gas_used, effective_gas_price, error, recorded_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`),
+		string(rec.Kind),
+		rec.TxHash,
+		rec.Nonce,
+		rec.ValidationID.String(),
+		rec.Epoch,
+		rec.DelegationCount,
+		string(rec.Status),
+		rec.BlockNumber,
+		rec.GasUsed,
+		rec.EffectiveGasPrice,
+		rec.Error,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("insert transaction %s: %w", rec.TxHash, err)
+	}
+	return nil
+}
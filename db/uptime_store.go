@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -10,132 +11,164 @@ import (
 
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
-	_ "github.com/lib/pq"
 )
 
-const refreshPrefix = "refresh_required:"
+// RefreshRequiredError is returned by StoreUptimeProof when the stored
+// uptime is higher than the one offered. The stored value should be re-signed
+// and stored instead.
+type RefreshRequiredError struct {
+	StoredUptime uint64
+}
+
+func (e *RefreshRequiredError) Error() string {
+	return fmt.Sprintf("stored uptime %d is higher, re-sign it instead", e.StoredUptime)
+}
 
 type UptimeProof struct {
 	ValidationID  ids.ID
 	UptimeSeconds uint64
 	SignedMessage *warp.Message
+	UpdatedAt     time.Time // when the row was last written
 }
 
-type UptimeStore struct {
-	db *sql.DB
-}
-
-func NewUptimeStore(dbURL string) (*UptimeStore, error) {
-	db, err := sql.Open("postgres", dbURL)
-	if err != nil {
-		return nil, fmt.Errorf("open db: %w", err)
+// StoreUptimeProof stores or updates an uptime proof in the database.
+// If a higher uptime already exists, it returns a *RefreshRequiredError so
+// the caller can re-sign the stored value.
+//
+// The read and the write happen in one transaction with the row locked (on
+// SQLite, the whole database), so concurrent writers for the same validator
+// are serialised and the stored uptime never goes down.
+func (s *sqlStore) StoreUptimeProof(
+	ctx context.Context,
+	validationID ids.ID,
+	uptimeSeconds uint64,
+	signedMessage *warp.Message,
+) error {
+	if s.dryRun {
+		logging.Infof("DRY RUN: skipping DB write of uptime %d for %s", uptimeSeconds, validationID.String())
+		return nil
 	}
 
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("ping db: %w", err)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
 	}
+	defer tx.Rollback()
 
-	if _, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS uptime_proofs (
-			validation_id TEXT PRIMARY KEY,
-			uptime_seconds BIGINT NOT NULL,
-			signed_message BYTEA NOT NULL,
-			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-			updated_at TIMESTAMP NOT NULL DEFAULT NOW()
-		)
-	`); err != nil {
-		return nil, fmt.Errorf("create schema: %w", err)
+	if err := s.storeUptimeProofTx(ctx, tx, validationID, uptimeSeconds, signedMessage); err != nil {
+		return err
 	}
-
-	logging.Info("connected to database and verified schema")
-
-	return &UptimeStore{db: db}, nil
-}
-
-func (s *UptimeStore) Close() error {
-	if s == nil || s.db == nil {
-		return nil
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit uptime proof: %w", err)
 	}
-	return s.db.Close()
+	return nil
 }
 
-// StoreUptimeProof stores or updates an uptime proof in the database.
-// If a higher uptime already exists, it returns an error with a special prefix
-// so the caller can re-sign the stored value.
-func (s *UptimeStore) StoreUptimeProof(
+// storeUptimeProofTx is StoreUptimeProof's compare-and-write within tx. A
+// proof with the stored uptime replaces its signed message; one below it is
+// not written and a *RefreshRequiredError is returned.
+func (s *sqlStore) storeUptimeProofTx(
+	ctx context.Context,
+	tx *sql.Tx,
 	validationID ids.ID,
 	uptimeSeconds uint64,
 	signedMessage *warp.Message,
 ) error {
-	var existingUptime uint64
-	var existingMsgBytes []byte
-
-	err := s.db.QueryRow(
-		`SELECT uptime_seconds, signed_message FROM uptime_proofs WHERE validation_id = $1`,
-		validationID.String(),
-	).Scan(&existingUptime, &existingMsgBytes)
-
-	switch {
-	case errors.Is(err, sql.ErrNoRows):
-		_, err := s.db.Exec(`
-			INSERT INTO uptime_proofs (validation_id, uptime_seconds, signed_message, updated_at)
-			VALUES ($1, $2, $3, $4)
-		`, validationID.String(), uptimeSeconds, signedMessage.Bytes(), time.Now())
-		if err != nil {
-			return fmt.Errorf("insert uptime proof: %w", err)
-		}
+	now := time.Now().UTC()
+
+	// A first proof is inserted outright. If another writer inserted the
+	// row concurrently, this waits for it to commit and then does nothing.
+	res, err := tx.ExecContext(ctx, s.dialect.rebind(`
+		INSERT INTO uptime_proofs (validation_id, uptime_seconds, signed_message, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (validation_id) DO NOTHING
+	`), validationID.String(), uptimeSeconds, signedMessage.Bytes(), now)
+	if err != nil {
+		return fmt.Errorf("insert uptime proof: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("insert uptime proof: %w", err)
+	} else if n == 1 {
 		return nil
+	}
 
-	case err != nil:
+	var existingUptime uint64
+	err = tx.QueryRowContext(ctx,
+		s.dialect.rebind(`SELECT uptime_seconds FROM uptime_proofs WHERE validation_id = $1`+s.dialect.forUpdate),
+		validationID.String(),
+	).Scan(&existingUptime)
+	if err != nil {
 		return fmt.Errorf("query existing uptime: %w", err)
 	}
 
 	switch {
 	case uptimeSeconds > existingUptime:
-		_, err = s.db.Exec(`
+		_, err = tx.ExecContext(ctx, s.dialect.rebind(`
 			UPDATE uptime_proofs
 			SET uptime_seconds = $2, signed_message = $3, updated_at = $4
 			WHERE validation_id = $1
-		`, validationID.String(), uptimeSeconds, signedMessage.Bytes(), time.Now())
+		`), validationID.String(), uptimeSeconds, signedMessage.Bytes(), now)
 		if err != nil {
 			return fmt.Errorf("update uptime proof: %w", err)
 		}
-		return nil
 
 	case uptimeSeconds == existingUptime:
 		logging.Infof("overwriting signed message for %s with same uptime %d", validationID.String(), uptimeSeconds)
-		_, err = s.db.Exec(`
+		_, err = tx.ExecContext(ctx, s.dialect.rebind(`
 			UPDATE uptime_proofs
 			SET signed_message = $2, updated_at = $3
 			WHERE validation_id = $1
-		`, validationID.String(), signedMessage.Bytes(), time.Now())
+		`), validationID.String(), signedMessage.Bytes(), now)
 		if err != nil {
 			return fmt.Errorf("refresh signed message: %w", err)
 		}
-		return nil
 
 	default:
-		logging.Infof("re-signing with stored higher uptime %d for %s", existingUptime, validationID.String())
-		return fmt.Errorf("%s%d", refreshPrefix, existingUptime)
+		logging.Infof("stored uptime %d for %s is higher than %d, not storing", existingUptime, validationID.String(), uptimeSeconds)
+		return &RefreshRequiredError{StoredUptime: existingUptime}
 	}
+	return nil
 }
 
-func IsRefreshRequiredError(err error) (bool, uint64) {
-	if err == nil {
-		return false, 0
+// ImportUptimeProofs stores proofs in a single transaction, each by the
+// same rules as StoreUptimeProof, so an error part way through leaves the
+// stored proofs as they were. A proof below the stored uptime is not
+// written and is counted as kept rather than failing the import. UpdatedAt
+// is ignored; written rows are stamped now. With dryRun set, the
+// transaction is rolled back, so the counts are what an import would do.
+func (s *sqlStore) ImportUptimeProofs(ctx context.Context, proofs []UptimeProof) (stored, kept int, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, proof := range proofs {
+		err := s.storeUptimeProofTx(ctx, tx, proof.ValidationID, proof.UptimeSeconds, proof.SignedMessage)
+		var refresh *RefreshRequiredError
+		switch {
+		case err == nil:
+			stored++
+		case errors.As(err, &refresh):
+			kept++
+		default:
+			return 0, 0, fmt.Errorf("import proof for %s: %w", proof.ValidationID.String(), err)
+		}
+	}
+
+	if s.dryRun {
+		logging.Infof("DRY RUN: rolling back import of %d proofs", stored)
+		return stored, kept, nil
 	}
-	msg := err.Error()
-	if len(msg) <= len(refreshPrefix) || msg[:len(refreshPrefix)] != refreshPrefix {
-		return false, 0
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("commit import: %w", err)
 	}
-	var v uint64
-	fmt.Sscanf(msg, refreshPrefix+"%d", &v)
-	return true, v
+	return stored, kept, nil
 }
 
-func (s *UptimeStore) GetAllUptimeProofs() (map[string]UptimeProof, error) {
-	rows, err := s.db.Query(
-		`SELECT validation_id, uptime_seconds, signed_message FROM uptime_proofs`,
+func (s *sqlStore) GetAllUptimeProofs(ctx context.Context) (map[string]UptimeProof, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT validation_id, uptime_seconds, signed_message, updated_at FROM uptime_proofs`,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("query uptime proofs: %w", err)
@@ -148,8 +181,9 @@ func (s *UptimeStore) GetAllUptimeProofs() (map[string]UptimeProof, error) {
 		var validationIDStr string
 		var uptimeSeconds uint64
 		var signedMessageBytes []byte
+		var updatedAt time.Time
 
-		if err := rows.Scan(&validationIDStr, &uptimeSeconds, &signedMessageBytes); err != nil {
+		if err := rows.Scan(&validationIDStr, &uptimeSeconds, &signedMessageBytes, &updatedAt); err != nil {
 			return nil, fmt.Errorf("scan uptime proof: %w", err)
 		}
 
@@ -167,6 +201,7 @@ func (s *UptimeStore) GetAllUptimeProofs() (map[string]UptimeProof, error) {
 			ValidationID:  validationID,
 			UptimeSeconds: uptimeSeconds,
 			SignedMessage: signedMessage,
+			UpdatedAt:     updatedAt,
 		}
 	}
 
@@ -0,0 +1,104 @@
+package delegation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"uptime-service/chain"
+	"uptime-service/logging"
+
+	"github.com/ava-labs/libevm/accounts/abi"
+	"github.com/ava-labs/libevm/common"
+	"github.com/ava-labs/libevm/common/hexutil"
+)
+
+const (
+	// sampleBatchSize is how many delegations planBatches estimates together
+	// to learn the per-delegation cost.
+	sampleBatchSize = 10
+
+	// fallbackBatchSize is used when the sample can't be estimated. Splitting
+	// sorts out whatever made the sample fail.
+	fallbackBatchSize = 20
+)
+
+// batchGasBudget returns how much gas one resolveRewards batch may use:
+// BatchGasSharePercent of the latest block's gas limit.
+func (c *Client) batchGasBudget(ctx context.Context) (uint64, error) {
+	if c.BatchGasSharePercent < 1 || c.BatchGasSharePercent > 100 {
+		return 0, fmt.Errorf("batch gas share must be between 1 and 100 percent, got %d", c.BatchGasSharePercent)
+	}
+	head, err := c.EthClient.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("get latest header: %w", err)
+	}
+	return head.GasLimit * uint64(c.BatchGasSharePercent) / 100, nil
+}
+
+// planBatches cuts ids into batches expected to fit budget. The size comes
+// from estimating a sample batch and dividing the budget by its gas per
+// delegation; since the sample's fixed transaction cost is spread over its
+// delegations, that errs on the small side. Each batch is still estimated
+// before it is sent and split if it turns out too big.
+func (c *Client) planBatches(ctx context.Context, parsedABI abi.ABI, ids [][32]byte, budget uint64) [][][32]byte {
+	size := fallbackBatchSize
+
+	sample := ids[:min(len(ids), sampleBatchSize)]
+	data, err := parsedABI.Pack("resolveRewards", sample)
+	if err == nil {
+		var gas uint64
+		gas, err = c.sender.EstimateGas(ctx, chain.TxRequest{
+			To:   common.HexToAddress(c.StakingManagerAddress),
+			Data: data,
+		})
+		if err == nil {
+			perDelegation := max(gas/uint64(len(sample)), 1)
+			size = max(int(budget/perDelegation), 1)
+		}
+	}
+	if err != nil {
+		logging.Infof("could not estimate a sample resolveRewards batch (%v), starting with batches of %d", err, size)
+	}
+
+	logging.Infof(
+		"resolving %d delegations in batches of up to %d (gas budget %d per batch)",
+		len(ids),
+		size,
+		budget,
+	)
+
+	batches := make([][][32]byte, 0, (len(ids)+size-1)/size)
+	for i := 0; i < len(ids); i += size {
+		batches = append(batches, ids[i:min(i+size, len(ids))])
+	}
+	return batches
+}
+
+// splitBatch halves a batch of at least two delegations.
+func splitBatch(batch [][32]byte) [][][32]byte {
+	mid := len(batch) / 2
+	return [][][32]byte{batch[:mid], batch[mid:]}
+}
+
+// describeBatch names a batch in logs and errors. Batches are re-cut as they
+// are split, so they are identified by their first delegation rather than
+// by number.
+func describeBatch(batch [][32]byte) string {
+	if len(batch) == 1 {
+		return fmt.Sprintf("delegation %s", hexutil.Encode(batch[0][:]))
+	}
+	return fmt.Sprintf("batch of %d delegations from %s", len(batch), hexutil.Encode(batch[0][:]))
+}
+
+// unsentError names every delegation in batches, which were never sent
+// because resolving stopped first.
+func unsentError(batches [][][32]byte) error {
+	var ids []string
+	for _, batch := range batches {
+		for _, id := range batch {
+			ids = append(ids, hexutil.Encode(id[:]))
+		}
+	}
+	return fmt.Errorf("%d delegations not attempted: %s", len(ids), strings.Join(ids, ", "))
+}
@@ -0,0 +1,221 @@
+package delegation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"uptime-service/chain"
+
+	"github.com/ava-labs/libevm/accounts/abi"
+	"github.com/ava-labs/libevm/common/hexutil"
+	"github.com/ava-labs/libevm/core/types"
+)
+
+// fakeSender estimates fixedGas plus gasPerDelegation for each delegation
+// in a batch, reverts any batch holding a delegation in reverts, and fails
+// the failSend-th Send (1-based, 0 for never).
+type fakeSender struct {
+	parsedABI        abi.ABI
+	fixedGas         uint64
+	gasPerDelegation uint64
+	estimateErr      error
+	reverts          map[[32]byte]bool
+	failSend         int
+
+	sends   int
+	sent    [][][32]byte
+	pending map[*chain.PendingTx][][32]byte
+}
+
+func newFakeSender(t *testing.T) *fakeSender {
+	t.Helper()
+	parsedABI, err := abi.JSON(strings.NewReader(resolveRewardsABI))
+	if err != nil {
+		t.Fatalf("parse ABI: %v", err)
+	}
+	return &fakeSender{
+		parsedABI:        parsedABI,
+		fixedGas:         1000,
+		gasPerDelegation: 100,
+		reverts:          map[[32]byte]bool{},
+		pending:          map[*chain.PendingTx][][32]byte{},
+	}
+}
+
+func (f *fakeSender) batch(req chain.TxRequest) [][32]byte {
+	values, err := f.parsedABI.Methods["resolveRewards"].Inputs.Unpack(req.Data[4:])
+	if err != nil {
+		panic(fmt.Sprintf("unpack resolveRewards: %v", err))
+	}
+	return values[0].([][32]byte)
+}
+
+func (f *fakeSender) EstimateGas(_ context.Context, req chain.TxRequest) (uint64, error) {
+	if f.estimateErr != nil {
+		return 0, f.estimateErr
+	}
+	return f.fixedGas + f.gasPerDelegation*uint64(len(f.batch(req))), nil
+}
+
+func (f *fakeSender) Send(_ context.Context, req chain.TxRequest) (*chain.PendingTx, error) {
+	batch := f.batch(req)
+	f.sends++
+	if f.sends == f.failSend {
+		return nil, errors.New("insufficient funds")
+	}
+	f.sent = append(f.sent, batch)
+	p := new(chain.PendingTx)
+	f.pending[p] = batch
+	return p, nil
+}
+
+func (f *fakeSender) Wait(_ context.Context, p *chain.PendingTx, _ map[string]error) (*types.Receipt, error) {
+	for _, id := range f.pending[p] {
+		if f.reverts[id] {
+			return nil, &chain.RevertError{}
+		}
+	}
+	return &types.Receipt{BlockNumber: big.NewInt(1)}, nil
+}
+
+// testIDs returns n distinct delegation IDs.
+func testIDs(n int) [][32]byte {
+	ids := make([][32]byte, n)
+	for i := range ids {
+		ids[i][31] = byte(i + 1)
+	}
+	return ids
+}
+
+func batchSizes(batches [][][32]byte) []int {
+	sizes := make([]int, len(batches))
+	for i, batch := range batches {
+		sizes[i] = len(batch)
+	}
+	return sizes
+}
+
+func TestPlanBatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		delegations int
+		budget      uint64
+		estimateErr error
+		want        []int
+	}{
+		// The sample of 10 estimates 2000 gas, 200 per delegation.
+		{name: "sized from the sample", delegations: 23, budget: 1000, want: []int{5, 5, 5, 5, 3}},
+		{name: "budget below one delegation", delegations: 3, budget: 50, want: []int{1, 1, 1}},
+		{name: "fewer than a batch", delegations: 4, budget: 100_000, want: []int{4}},
+		{name: "sample fails estimation", delegations: 25, budget: 1000, estimateErr: errors.New("execution reverted"), want: []int{20, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := newFakeSender(t)
+			sender.estimateErr = tt.estimateErr
+			c := &Client{sender: sender}
+
+			ids := testIDs(tt.delegations)
+			batches := c.planBatches(context.Background(), sender.parsedABI, ids, tt.budget)
+			if got := batchSizes(batches); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("batch sizes = %v, want %v", got, tt.want)
+			}
+			var n int
+			for _, batch := range batches {
+				for _, id := range batch {
+					if id != ids[n] {
+						t.Fatalf("delegation %d out of order", n)
+					}
+					n++
+				}
+			}
+		})
+	}
+}
+
+func TestResolveBatchesSplitsRevertsDownToSingles(t *testing.T) {
+	sender := newFakeSender(t)
+	ids := testIDs(8)
+	bad := ids[5]
+	sender.reverts[bad] = true
+	c := &Client{sender: sender}
+
+	var result ResolveResult
+	err := c.resolveBatches(context.Background(), sender.parsedABI, [][][32]byte{ids}, 100_000, &result)
+
+	if !errors.Is(err, chain.ErrReverted) {
+		t.Errorf("got error %v, want the bad delegation's revert", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), hexutil.Encode(bad[:])) {
+		t.Errorf("error does not name the bad delegation: %v", err)
+	}
+	if result.Resolved != 7 {
+		t.Errorf("resolved %d delegations, want 7", result.Resolved)
+	}
+	// 8 reverts, then 4+4, then 2+2 of the bad half, then 1+1.
+	if got := batchSizes(sender.sent); fmt.Sprint(got) != fmt.Sprint([]int{8, 4, 4, 2, 2, 1, 1}) {
+		t.Errorf("sent batches of %v", got)
+	}
+	if len(result.Transactions) != len(sender.sent) {
+		t.Errorf("recorded %d transactions for %d sent", len(result.Transactions), len(sender.sent))
+	}
+}
+
+func TestResolveBatchesSendsOverBudgetSingles(t *testing.T) {
+	sender := newFakeSender(t)
+	ids := testIDs(2)
+	c := &Client{sender: sender}
+
+	// Every estimate is over the budget: the pair is split, and each single
+	// is sent anyway since it can't be split further.
+	var result ResolveResult
+	err := c.resolveBatches(context.Background(), sender.parsedABI, [][][32]byte{ids}, 50, &result)
+	if err != nil {
+		t.Fatalf("resolveBatches: %v", err)
+	}
+	if result.Resolved != 2 {
+		t.Errorf("resolved %d delegations, want 2", result.Resolved)
+	}
+	if got := batchSizes(sender.sent); fmt.Sprint(got) != fmt.Sprint([]int{1, 1}) {
+		t.Errorf("sent batches of %v, want two singles", got)
+	}
+}
+
+func TestResolveBatchesStopsAfterFailedSend(t *testing.T) {
+	sender := newFakeSender(t)
+	ids := testIDs(5)
+	sender.reverts[ids[0]] = true
+	sender.failSend = 2
+	c := &Client{sender: sender}
+
+	// The pair is sent and reverts, the send of ids[2] fails, so the rest of
+	// the queue and the halves of the reverted pair are never sent.
+	queue := [][][32]byte{ids[0:2], ids[2:3], ids[3:5]}
+	var result ResolveResult
+	err := c.resolveBatches(context.Background(), sender.parsedABI, queue, 100_000, &result)
+	if err == nil {
+		t.Fatalf("resolveBatches succeeded after a failed send")
+	}
+
+	if len(sender.sent) != 1 {
+		t.Errorf("sent %d batches, want only the first", len(sender.sent))
+	}
+	if result.Resolved != 0 {
+		t.Errorf("resolved %d delegations, want 0", result.Resolved)
+	}
+	var unsent []string
+	for _, i := range []int{3, 4, 0, 1} {
+		unsent = append(unsent, hexutil.Encode(ids[i][:]))
+	}
+	want := "4 delegations not attempted: " + strings.Join(unsent, ", ")
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("error does not list exactly the unsent delegations\n got: %v\nwant: %s", err, want)
+	}
+	if !strings.Contains(err.Error(), "insufficient funds") {
+		t.Errorf("error does not carry the send failure: %v", err)
+	}
+}
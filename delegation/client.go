@@ -1,43 +1,26 @@
 package delegation
 
 import (
-	"bytes"
 	"context"
-	"crypto/ecdsa"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"math/big"
-	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"uptime-service/chain"
 	"uptime-service/logging"
+	"uptime-service/subgraph"
 
+	"github.com/ava-labs/avalanche-tooling-sdk-go/validatormanager"
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/libevm/accounts/abi"
 	"github.com/ava-labs/libevm/common"
 	"github.com/ava-labs/libevm/core/types"
-	"github.com/ava-labs/libevm/crypto"
 	"github.com/ava-labs/libevm/ethclient"
 )
 
-type GraphQLQuery struct {
-	Query     string                 `json:"query"`
-	Variables map[string]interface{} `json:"variables,omitempty"`
-}
-
-type GraphQLResponse struct {
-	Data   GraphQLData `json:"data"`
-	Errors []struct {
-		Message string `json:"message"`
-	} `json:"errors,omitempty"`
-}
-
-type GraphQLData struct {
-	Delegations []Delegation `json:"delegations"`
-}
-
 type Delegation struct {
 	ID           string `json:"id"`
 	ValidationID string `json:"validationID"`
@@ -45,37 +28,53 @@ type Delegation struct {
 
 type Client struct {
 	GraphQLEndpoint       string
-	RPC                   string
 	StakingManagerAddress string
-	PrivateKey            *ecdsa.PrivateKey
 	PublicAddress         common.Address
 	EthClient             *ethclient.Client
+	BatchGasSharePercent  int // share of the block gas limit each resolveRewards batch may use
+	sender                txSender
+	subgraph              *subgraph.Client
+	dryRun                bool
 }
 
-func NewClient(graphqlEndpoint, rpcURL, stakingManagerAddr, privateKeyHex string) (*Client, error) {
-	privKey, err := crypto.HexToECDSA(strings.TrimPrefix(privateKeyHex, "0x"))
-	if err != nil {
-		return nil, fmt.Errorf("parse private key: %w", err)
-	}
-
-	pubAddr := crypto.PubkeyToAddress(privKey.PublicKey)
-
-	ethClient, err := ethclient.Dial(rpcURL)
-	if err != nil {
-		return nil, fmt.Errorf("connect EVM client: %w", err)
-	}
+// txSender is the part of *chain.Sender the client sends resolveRewards
+// transactions with.
+type txSender interface {
+	EstimateGas(ctx context.Context, req chain.TxRequest) (uint64, error)
+	Send(ctx context.Context, req chain.TxRequest) (*chain.PendingTx, error)
+	Wait(ctx context.Context, p *chain.PendingTx, signatures map[string]error) (*types.Receipt, error)
+}
 
+// NewClient builds a delegation client that sends resolveRewards through
+// sender, sharing its nonces with every other client built on the same
+// sender. With dryRun set, ResolveRewards only simulates its transactions.
+func NewClient(
+	graphqlEndpoint, stakingManagerAddr string,
+	sender *chain.Sender,
+	batchGasSharePercent int,
+	dryRun bool,
+) *Client {
 	return &Client{
 		GraphQLEndpoint:       graphqlEndpoint,
-		RPC:                   rpcURL,
 		StakingManagerAddress: stakingManagerAddr,
-		PrivateKey:            privKey,
-		PublicAddress:         pubAddr,
-		EthClient:             ethClient,
-	}, nil
+		PublicAddress:         sender.From(),
+		EthClient:             sender.Client(),
+		BatchGasSharePercent:  batchGasSharePercent,
+		sender:                sender,
+		subgraph:              subgraph.NewClient(graphqlEndpoint),
+		dryRun:                dryRun,
+	}
 }
 
-func (c *Client) GetDelegationsForValidator(validationID string) ([]Delegation, error) {
+// GetDelegationsForValidator returns the delegations of validationID that
+// are due rewards for epochNum: those that started before the epoch ended
+// (epochEnd) and whose last rewarded epoch is not already epochNum.
+func (c *Client) GetDelegationsForValidator(
+	ctx context.Context,
+	validationID string,
+	epochNum uint64,
+	epochEnd time.Time,
+) ([]Delegation, error) {
 	validationIDBytes, err := ids.FromString(validationID)
 	if err != nil {
 		return nil, fmt.Errorf("parse validation ID %s: %w", validationID, err)
@@ -83,13 +82,16 @@ func (c *Client) GetDelegationsForValidator(validationID string) ([]Delegation,
 	formattedID := ids.ID(validationIDBytes).Hex()
 
 	query := `
-	query GetDelegations($validationID: Bytes!) {
+	query GetDelegations($validationID: Bytes!, $epoch: Int!, $epochEnd: BigInt!, $first: Int!, $lastID: Bytes!) {
 		delegations(
-			first: 1000,
+			first: $first,
+			orderBy: id,
+			orderDirection: asc,
 			where: {
+				id_gt: $lastID,
 				validationID: $validationID,
-				lastRewardedEpoch_not: 674,
-				startedAt_lte: "1775022552"
+				lastRewardedEpoch_not: $epoch,
+				startedAt_lte: $epochEnd
 			}
 		) {
 			id
@@ -97,60 +99,74 @@ func (c *Client) GetDelegationsForValidator(validationID string) ([]Delegation,
 		}
 	}`
 
-	variables := map[string]interface{}{
-		"validationID": formattedID,
-	}
-
-	graphqlQuery := GraphQLQuery{
-		Query:     query,
-		Variables: variables,
-	}
-
-	jsonData, err := json.Marshal(graphqlQuery)
-	if err != nil {
-		return nil, fmt.Errorf("marshal GraphQL query: %w", err)
-	}
-
-	req, err := http.NewRequest("POST", c.GraphQLEndpoint, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("create HTTP request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	httpClient := &http.Client{Timeout: 10 * time.Second}
-	resp, err := httpClient.Do(req)
+	delegations, err := subgraph.Paginate(ctx, c.subgraph, subgraph.PageQuery{
+		Query: query,
+		Field: "delegations",
+		Variables: map[string]interface{}{
+			"validationID": formattedID,
+			"epoch":        epochNum,
+			"epochEnd":     strconv.FormatInt(epochEnd.Unix(), 10),
+		},
+		StartAfter: "0x",
+	}, func(d Delegation) string { return d.ID })
 	if err != nil {
-		return nil, fmt.Errorf("execute GraphQL request: %w", err)
+		return nil, fmt.Errorf("query delegations: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
+	return delegations, nil
+}
 
-	var graphqlResp GraphQLResponse
-	if err := json.NewDecoder(resp.Body).Decode(&graphqlResp); err != nil {
-		return nil, fmt.Errorf("decode GraphQL response: %w", err)
-	}
+const resolveRewardsABI = `[{"inputs":[{"internalType":"bytes32[]","name":"delegationIDs","type":"bytes32[]"}],"name":"resolveRewards","outputs":[],"stateMutability":"nonpayable","type":"function"}]`
 
-	if len(graphqlResp.Errors) > 0 {
-		return nil, fmt.Errorf("GraphQL error: %s", graphqlResp.Errors[0].Message)
-	}
+// ResolveResult counts what ResolveRewards did with the delegations it was
+// given, and lists the transactions it sent.
+type ResolveResult struct {
+	Resolved     int // resolved on-chain; in a dry run, would have been
+	Skipped      int // already resolved or not eligible according to the chain
+	Transactions []BatchTx
+}
 
-	return graphqlResp.Data.Delegations, nil
+// BatchTx is one resolveRewards transaction and how it ended. TxHash is the
+// version that was mined if any, else the latest one sent; Receipt is nil
+// unless it was mined, and Err is what waiting for it returned.
+type BatchTx struct {
+	TxHash      common.Hash
+	Nonce       uint64
+	Delegations int
+	Receipt     *types.Receipt
+	Err         error
 }
 
-func (c *Client) ResolveRewards(delegations []Delegation) error {
+// ResolveRewards resolves rewards for delegations for epochNum, which ended
+// at epochEnd.
+//
+// Each delegation's on-chain state is read first, and those the chain says
+// are already resolved or not eligible are skipped (see filterResolvable).
+// The rest are batched: batches are sized so that each fills BatchGasSharePercent of the block gas
+// limit (see planBatches) and are sent back to back as dynamic-fee
+// transactions, then waited on. A batch that fails gas estimation, estimates
+// above the budget, or reverts on-chain (out of gas included) is split in
+// half and retried, down to single delegations, so one bad delegation only
+// fails itself. A failed send or a cancelled ctx stops all further sends,
+// retries of the current wave included, as those would fail the same way.
+// The returned error joins whatever could not be resolved: reverts carry a
+// *chain.RevertError, batches not mined in time chain.ErrReceiptTimeout, and
+// the delegations never sent are listed by ID.
+func (c *Client) ResolveRewards(
+	ctx context.Context,
+	delegations []Delegation,
+	epochNum uint64,
+	epochEnd time.Time,
+) (ResolveResult, error) {
+	var result ResolveResult
 	if len(delegations) == 0 {
 		logging.Info("no delegations to resolve")
-		return nil
+		return result, nil
 	}
 
-	const abiJSON = `[{"inputs":[{"internalType":"bytes32[]","name":"delegationIDs","type":"bytes32[]"}],"name":"resolveRewards","outputs":[],"stateMutability":"nonpayable","type":"function"}]`
-
-	parsedABI, err := abi.JSON(strings.NewReader(abiJSON))
+	parsedABI, err := abi.JSON(strings.NewReader(resolveRewardsABI))
 	if err != nil {
-		return fmt.Errorf("parse ABI: %w", err)
+		return result, fmt.Errorf("parse ABI: %w", err)
 	}
 
 	delegationIDs := make([][32]byte, 0, len(delegations))
@@ -172,66 +188,155 @@ func (c *Client) ResolveRewards(delegations []Delegation) error {
 	}
 
 	if len(delegationIDs) == 0 {
-		return fmt.Errorf("no valid delegation IDs after parsing")
+		return result, fmt.Errorf("no valid delegation IDs after parsing")
 	}
 
-	const batchSize = 20
-	for i := 0; i < len(delegationIDs); i += batchSize {
-		end := i + batchSize
-		if end > len(delegationIDs) {
-			end = len(delegationIDs)
-		}
-		batch := delegationIDs[i:end]
+	delegationIDs, result.Skipped, err = c.filterResolvable(ctx, delegationIDs, epochNum, epochEnd)
+	if err != nil {
+		return result, err
+	}
+	if len(delegationIDs) == 0 {
+		logging.Infof("all %d delegations are already resolved or not eligible", result.Skipped)
+		return result, nil
+	}
 
-		nonce, err := c.EthClient.PendingNonceAt(context.Background(), c.PublicAddress)
-		if err != nil {
-			return fmt.Errorf("get nonce: %w", err)
-		}
+	budget, err := c.batchGasBudget(ctx)
+	if err != nil {
+		return result, err
+	}
+	queue := c.planBatches(ctx, parsedABI, delegationIDs, budget)
 
-		gasPrice, err := c.EthClient.SuggestGasPrice(context.Background())
-		if err != nil {
-			return fmt.Errorf("get gas price: %w", err)
-		}
+	err = c.resolveBatches(ctx, parsedABI, queue, budget, &result)
+	logging.Infof("%d of %d delegations resolved, %d skipped", result.Resolved, len(delegationIDs), result.Skipped)
+	return result, err
+}
 
-		data, err := parsedABI.Pack("resolveRewards", batch)
-		if err != nil {
-			return fmt.Errorf("pack tx data: %w", err)
-		}
+// resolveBatches sends the batches in queue as described on ResolveRewards,
+// adding what was resolved and the transactions sent to result, and returns
+// the joined errors.
+func (c *Client) resolveBatches(
+	ctx context.Context,
+	parsedABI abi.ABI,
+	queue [][][32]byte,
+	budget uint64,
+	result *ResolveResult,
+) error {
+	type sentBatch struct {
+		ids     [][32]byte
+		pending *chain.PendingTx
+	}
 
-		contractAddr := common.HexToAddress(c.StakingManagerAddress)
-		tx := types.NewTransaction(
-			nonce,
-			contractAddr,
-			big.NewInt(0),
-			3000000,
-			gasPrice,
-			data,
-		)
-
-		chainID, err := c.EthClient.ChainID(context.Background())
-		if err != nil {
-			return fmt.Errorf("get chain ID: %w", err)
-		}
+	contractAddr := common.HexToAddress(c.StakingManagerAddress)
+	var errs []error
+
+	// Once sending stops, whatever is still queued, including the halves of
+	// batches that reverted in the current wave, is collected in unsent
+	// instead of being tried.
+	var stopped bool
+	var unsent [][][32]byte
+
+	for len(queue) > 0 {
+		var sent []sentBatch
+		var retry [][][32]byte
+
+		for len(queue) > 0 {
+			if err := ctx.Err(); err != nil {
+				errs = append(errs, fmt.Errorf("resolve rewards interrupted: %w", err))
+				stopped, unsent = true, append(unsent, queue...)
+				queue = nil
+				break
+			}
+			batch := queue[0]
+			queue = queue[1:]
 
-		signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), c.PrivateKey)
-		if err != nil {
-			return fmt.Errorf("sign tx: %w", err)
-		}
+			data, err := parsedABI.Pack("resolveRewards", batch)
+			if err != nil {
+				return fmt.Errorf("pack tx data: %w", err)
+			}
+			req := chain.TxRequest{To: contractAddr, Data: data}
+
+			req.Gas, err = c.sender.EstimateGas(ctx, req)
+			if err != nil {
+				err = chain.DecodeCallError(err, validatormanager.ErrorSignatureToError)
+				if len(batch) > 1 {
+					logging.Infof("%s fails gas estimation (%v), splitting it", describeBatch(batch), err)
+					queue = append(splitBatch(batch), queue...)
+					continue
+				}
+				errs = append(errs, fmt.Errorf("%s: %w", describeBatch(batch), err))
+				continue
+			}
+			if req.Gas > budget && len(batch) > 1 {
+				logging.Infof("%s needs %d gas, over the %d budget, splitting it", describeBatch(batch), req.Gas, budget)
+				queue = append(splitBatch(batch), queue...)
+				continue
+			}
+
+			if c.dryRun {
+				logging.Infof("DRY RUN: resolveRewards for %s would succeed (estimated gas %d)", describeBatch(batch), req.Gas)
+				result.Resolved += len(batch)
+				continue
+			}
 
-		if err := c.EthClient.SendTransaction(context.Background(), signedTx); err != nil {
-			return fmt.Errorf("send tx: %w", err)
+			pending, err := c.sender.Send(ctx, req)
+			if err != nil {
+				// Not a revert — estimation just passed — so the node or the
+				// key is the problem; sending more would fail the same way.
+				errs = append(errs, fmt.Errorf("%s: %w", describeBatch(batch), err))
+				stopped, unsent = true, append(unsent, queue...)
+				queue = nil
+				break
+			}
+			logging.Infof(
+				"submitted resolveRewards tx for %s, tx hash: %s, nonce %d, gas limit %d",
+				describeBatch(batch),
+				pending.Hash().Hex(),
+				pending.Nonce(),
+				req.Gas,
+			)
+			sent = append(sent, sentBatch{ids: batch, pending: pending})
 		}
 
-		logging.Infof(
-			"submitted resolveRewards tx (batch %d/%d) with %d delegations, tx hash: %s",
-			(i/batchSize)+1,
-			(len(delegationIDs)+batchSize-1)/batchSize,
-			len(batch),
-			signedTx.Hash().Hex(),
-		)
+		for _, b := range sent {
+			receipt, err := c.sender.Wait(ctx, b.pending, validatormanager.ErrorSignatureToError)
+			tx := BatchTx{
+				TxHash:      b.pending.Hash(),
+				Nonce:       b.pending.Nonce(),
+				Delegations: len(b.ids),
+				Receipt:     receipt,
+				Err:         err,
+			}
+			if receipt != nil {
+				tx.TxHash = receipt.TxHash
+			}
+			result.Transactions = append(result.Transactions, tx)
+
+			switch {
+			case err == nil:
+				result.Resolved += len(b.ids)
+				logging.Infof(
+					"resolveRewards for %s mined in block %s (gas used %d)",
+					describeBatch(b.ids),
+					receipt.BlockNumber,
+					receipt.GasUsed,
+				)
+			case errors.Is(err, chain.ErrReverted) && len(b.ids) > 1:
+				logging.Infof("%s reverted (%v), splitting it", describeBatch(b.ids), err)
+				retry = append(retry, splitBatch(b.ids)...)
+			default:
+				errs = append(errs, fmt.Errorf("%s: %w", describeBatch(b.ids), err))
+			}
+		}
 
-		time.Sleep(4 * time.Second)
+		if stopped {
+			unsent = append(unsent, retry...)
+			break
+		}
+		queue = retry
 	}
 
-	return nil
+	if len(unsent) > 0 {
+		errs = append(errs, unsentError(unsent))
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,200 @@
+package delegation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"uptime-service/logging"
+
+	ethereum "github.com/ava-labs/libevm"
+	"github.com/ava-labs/libevm/accounts/abi"
+	"github.com/ava-labs/libevm/common"
+	"github.com/ava-labs/libevm/common/hexutil"
+)
+
+// DelegatorStatus mirrors the staking manager's DelegatorStatus enum.
+type DelegatorStatus uint8
+
+const (
+	DelegatorStatusUnknown DelegatorStatus = iota
+	DelegatorStatusPendingAdded
+	DelegatorStatusActive
+	DelegatorStatusPendingRemoved
+)
+
+func (s DelegatorStatus) String() string {
+	switch s {
+	case DelegatorStatusUnknown:
+		return "unknown"
+	case DelegatorStatusPendingAdded:
+		return "pending added"
+	case DelegatorStatusActive:
+		return "active"
+	case DelegatorStatusPendingRemoved:
+		return "pending removed"
+	default:
+		return fmt.Sprintf("status %d", uint8(s))
+	}
+}
+
+// DelegatorState is the part of a delegation's on-chain record that decides
+// whether its rewards can be resolved.
+type DelegatorState struct {
+	Status            DelegatorStatus
+	ValidationID      [32]byte
+	StartTime         uint64
+	LastRewardedEpoch uint64
+}
+
+// getDelegatorInfo returns the staking manager's Delegator struct, which on
+// Beam carries lastRewardedEpoch after the standard fields.
+const delegatorInfoABI = `[{"inputs":[{"internalType":"bytes32","name":"delegationID","type":"bytes32"}],"name":"getDelegatorInfo","outputs":[{"components":[{"internalType":"enum DelegatorStatus","name":"status","type":"uint8"},{"internalType":"address","name":"owner","type":"address"},{"internalType":"bytes32","name":"validationID","type":"bytes32"},{"internalType":"uint64","name":"weight","type":"uint64"},{"internalType":"uint64","name":"startTime","type":"uint64"},{"internalType":"uint64","name":"startingNonce","type":"uint64"},{"internalType":"uint64","name":"endingNonce","type":"uint64"},{"internalType":"uint64","name":"lastRewardedEpoch","type":"uint64"}],"internalType":"struct Delegator","name":"","type":"tuple"}],"stateMutability":"view","type":"function"}]`
+
+// delegatorInfoSize is the length of a getDelegatorInfo return: the tuple
+// is static, so it is one 32-byte word per field.
+const delegatorInfoSize = 8 * 32
+
+// errDelegatorLayout is returned by GetDelegatorState when the staking
+// manager's getDelegatorInfo does not return the Delegator struct above,
+// e.g. on a deployment without lastRewardedEpoch.
+var errDelegatorLayout = errors.New("getDelegatorInfo returned an unexpected layout")
+
+// stateReadConcurrency bounds the getDelegatorInfo calls filterResolvable
+// has in flight at once.
+const stateReadConcurrency = 8
+
+// delegatorInfo matches the tuple getDelegatorInfo returns, field for field,
+// so abi.ConvertType can copy the decoded value into it.
+type delegatorInfo struct {
+	Status            uint8
+	Owner             common.Address
+	ValidationID      [32]byte
+	Weight            uint64
+	StartTime         uint64
+	StartingNonce     uint64
+	EndingNonce       uint64
+	LastRewardedEpoch uint64
+}
+
+// GetDelegatorState reads a delegation's current state from the staking
+// manager.
+func (c *Client) GetDelegatorState(ctx context.Context, delegationID [32]byte) (DelegatorState, error) {
+	parsedABI, err := abi.JSON(strings.NewReader(delegatorInfoABI))
+	if err != nil {
+		return DelegatorState{}, fmt.Errorf("parse ABI: %w", err)
+	}
+
+	data, err := parsedABI.Pack("getDelegatorInfo", delegationID)
+	if err != nil {
+		return DelegatorState{}, fmt.Errorf("pack getDelegatorInfo: %w", err)
+	}
+
+	to := common.HexToAddress(c.StakingManagerAddress)
+	out, err := c.EthClient.CallContract(ctx, ethereum.CallMsg{To: &to, Data: data}, nil)
+	if err != nil {
+		return DelegatorState{}, fmt.Errorf("call getDelegatorInfo: %w", err)
+	}
+
+	return decodeDelegatorInfo(parsedABI, out)
+}
+
+// decodeDelegatorInfo decodes the return data of a getDelegatorInfo call.
+func decodeDelegatorInfo(parsedABI abi.ABI, out []byte) (DelegatorState, error) {
+	if len(out) != delegatorInfoSize {
+		return DelegatorState{}, fmt.Errorf("%w: %d bytes, want %d", errDelegatorLayout, len(out), delegatorInfoSize)
+	}
+	values, err := parsedABI.Unpack("getDelegatorInfo", out)
+	if err != nil {
+		return DelegatorState{}, fmt.Errorf("unpack getDelegatorInfo: %w", err)
+	}
+	info := *abi.ConvertType(values[0], new(delegatorInfo)).(*delegatorInfo)
+
+	return DelegatorState{
+		Status:            DelegatorStatus(info.Status),
+		ValidationID:      info.ValidationID,
+		StartTime:         info.StartTime,
+		LastRewardedEpoch: info.LastRewardedEpoch,
+	}, nil
+}
+
+// skipReason says why a delegation in state should not be resolved for
+// epochNum, or returns "" if it should. Epoch 0 can't be told apart from
+// "never rewarded", so it is never treated as already resolved.
+func skipReason(state DelegatorState, epochNum uint64, epochEnd time.Time) string {
+	switch {
+	case state.Status == DelegatorStatusUnknown:
+		return "not found on-chain"
+	case state.Status == DelegatorStatusPendingAdded:
+		return "registration not completed"
+	case state.Status != DelegatorStatusActive && state.Status != DelegatorStatusPendingRemoved:
+		return fmt.Sprintf("not eligible in %s", state.Status)
+	case epochNum > 0 && state.LastRewardedEpoch >= epochNum:
+		return fmt.Sprintf("already resolved (last rewarded epoch %d)", state.LastRewardedEpoch)
+	case state.StartTime > uint64(epochEnd.Unix()):
+		return fmt.Sprintf("started after epoch %d ended", epochNum)
+	}
+	return ""
+}
+
+// filterResolvable drops the delegations the chain says are already
+// resolved or not eligible for epochNum, logging why. The subgraph can lag
+// the chain, and resending those only wastes gas on reverts. States are read
+// stateReadConcurrency at a time. A delegation whose state can't be read is
+// kept: batch splitting isolates it if it does revert. If the staking
+// manager doesn't return the expected layout at all, nothing is filtered.
+func (c *Client) filterResolvable(
+	ctx context.Context,
+	ids [][32]byte,
+	epochNum uint64,
+	epochEnd time.Time,
+) (kept [][32]byte, skipped int, err error) {
+	states := make([]DelegatorState, len(ids))
+	errs := make([]error, len(ids))
+	sem := make(chan struct{}, stateReadConcurrency)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			states[i], errs[i] = c.GetDelegatorState(ctx, id)
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, 0, fmt.Errorf("check delegation state: %w", err)
+	}
+
+	for _, err := range errs {
+		if errors.Is(err, errDelegatorLayout) {
+			logging.Errorf("cannot read delegation states, resolving all %d unfiltered: %v", len(ids), err)
+			return ids, 0, nil
+		}
+	}
+
+	kept = make([][32]byte, 0, len(ids))
+	for i, id := range ids {
+		if errs[i] != nil {
+			logging.Errorf("read on-chain state of delegation %s, including it anyway: %v", hexutil.Encode(id[:]), errs[i])
+			kept = append(kept, id)
+			continue
+		}
+		if reason := skipReason(states[i], epochNum, epochEnd); reason != "" {
+			logging.Infof("⏩ skipping delegation %s: %s", hexutil.Encode(id[:]), reason)
+			skipped++
+			continue
+		}
+		kept = append(kept, id)
+	}
+	return kept, skipped, nil
+}
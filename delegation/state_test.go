@@ -0,0 +1,83 @@
+package delegation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/libevm/accounts/abi"
+	"github.com/ava-labs/libevm/common"
+)
+
+// activeDelegatorInfo is an eth_call return of getDelegatorInfo for an
+// active delegation, one 32-byte word per Delegator field.
+const activeDelegatorInfo = "" +
+	"0000000000000000000000000000000000000000000000000000000000000002" + // status: active
+	"0000000000000000000000008db97c7cece249c2b98bdc0226cc4c2a57bf52fc" + // owner
+	"5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f5f" + // validationID
+	"00000000000000000000000000000000000000000000000000000000000f4240" + // weight
+	"0000000000000000000000000000000000000000000000000000000067748580" + // startTime
+	"0000000000000000000000000000000000000000000000000000000000000003" + // startingNonce
+	"0000000000000000000000000000000000000000000000000000000000000000" + // endingNonce
+	"0000000000000000000000000000000000000000000000000000000000000029" // lastRewardedEpoch
+
+func TestDecodeDelegatorInfo(t *testing.T) {
+	parsedABI, err := abi.JSON(strings.NewReader(delegatorInfoABI))
+	if err != nil {
+		t.Fatalf("parse ABI: %v", err)
+	}
+
+	state, err := decodeDelegatorInfo(parsedABI, common.FromHex(activeDelegatorInfo))
+	if err != nil {
+		t.Fatalf("decodeDelegatorInfo: %v", err)
+	}
+	want := DelegatorState{
+		Status:            DelegatorStatusActive,
+		ValidationID:      [32]byte(common.FromHex(strings.Repeat("5f", 32))),
+		StartTime:         1735689600,
+		LastRewardedEpoch: 41,
+	}
+	if state != want {
+		t.Errorf("decoded %+v, want %+v", state, want)
+	}
+
+	// The standard Delegator struct, without lastRewardedEpoch, and an empty
+	// return (no such function) are both reported as the wrong layout.
+	for _, out := range []string{activeDelegatorInfo[:7*64], ""} {
+		_, err := decodeDelegatorInfo(parsedABI, common.FromHex(out))
+		if !errors.Is(err, errDelegatorLayout) {
+			t.Errorf("decoding %d bytes: got %v, want errDelegatorLayout", len(out)/2, err)
+		}
+	}
+}
+
+func TestSkipReason(t *testing.T) {
+	epochEnd := time.Unix(2_000_000, 0)
+	started := uint64(1_000_000)
+
+	tests := []struct {
+		name   string
+		state  DelegatorState
+		epoch  uint64
+		reason string // "" to resolve
+	}{
+		{"unknown", DelegatorState{Status: DelegatorStatusUnknown}, 5, "not found on-chain"},
+		{"pending added", DelegatorState{Status: DelegatorStatusPendingAdded, StartTime: started}, 5, "registration not completed"},
+		{"unexpected status", DelegatorState{Status: 7, StartTime: started}, 5, "not eligible in status 7"},
+		{"active, due", DelegatorState{Status: DelegatorStatusActive, StartTime: started, LastRewardedEpoch: 4}, 5, ""},
+		{"pending removed, due", DelegatorState{Status: DelegatorStatusPendingRemoved, StartTime: started, LastRewardedEpoch: 4}, 5, ""},
+		{"already resolved", DelegatorState{Status: DelegatorStatusActive, StartTime: started, LastRewardedEpoch: 5}, 5, "already resolved (last rewarded epoch 5)"},
+		{"resolved later epoch", DelegatorState{Status: DelegatorStatusActive, StartTime: started, LastRewardedEpoch: 6}, 5, "already resolved (last rewarded epoch 6)"},
+		{"epoch 0, never rewarded", DelegatorState{Status: DelegatorStatusActive, StartTime: started}, 0, ""},
+		{"started after epoch", DelegatorState{Status: DelegatorStatusActive, StartTime: uint64(epochEnd.Unix()) + 1}, 5, "started after epoch 5 ended"},
+		{"started at epoch end", DelegatorState{Status: DelegatorStatusActive, StartTime: uint64(epochEnd.Unix())}, 5, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := skipReason(tt.state, tt.epoch, epochEnd); got != tt.reason {
+				t.Errorf("skipReason = %q, want %q", got, tt.reason)
+			}
+		})
+	}
+}
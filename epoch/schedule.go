@@ -0,0 +1,94 @@
+package epoch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule describes the staking manager's fixed-length epochs. Epoch 0
+// begins at Start and every epoch lasts exactly Duration.
+type Schedule struct {
+	Start    time.Time
+	Duration time.Duration
+}
+
+// NewSchedule builds a Schedule from a unix start timestamp and an epoch
+// length in seconds, the units both the config file and the contract use.
+func NewSchedule(startUnix, durationSeconds int64) (Schedule, error) {
+	if durationSeconds <= 0 {
+		return Schedule{}, fmt.Errorf("epoch duration must be positive, got %d", durationSeconds)
+	}
+	if startUnix < 0 {
+		return Schedule{}, fmt.Errorf("epoch start must not be negative, got %d", startUnix)
+	}
+	return Schedule{
+		Start:    time.Unix(startUnix, 0).UTC(),
+		Duration: time.Duration(durationSeconds) * time.Second,
+	}, nil
+}
+
+// At returns the epoch containing t. Times before Start map to epoch 0.
+func (s Schedule) At(t time.Time) uint64 {
+	if !t.After(s.Start) {
+		return 0
+	}
+	return uint64(t.Sub(s.Start) / s.Duration)
+}
+
+// StartOf returns the time epoch n begins.
+func (s Schedule) StartOf(n uint64) time.Time {
+	return s.Start.Add(time.Duration(n) * s.Duration)
+}
+
+// EndOf returns the time epoch n ends, which is also when epoch n+1 begins.
+func (s Schedule) EndOf(n uint64) time.Time {
+	return s.StartOf(n + 1)
+}
+
+// NextRun returns the first time strictly after `after` that sits offset
+// into an epoch, together with the epoch it falls in. The daemon uses it to
+// line its cycles up with epoch boundaries.
+func (s Schedule) NextRun(after time.Time, offset time.Duration) (time.Time, uint64) {
+	n := s.At(after)
+	for {
+		at := s.StartOf(n).Add(offset)
+		if at.After(after) {
+			return at, n
+		}
+		n++
+	}
+}
+
+// maxRangeLen caps how many epochs a single range argument may cover.
+const maxRangeLen = 1000
+
+// ParseRange parses an epoch argument of the form "N" or "N-M" (inclusive)
+// and returns the epochs it covers in ascending order.
+func ParseRange(spec string) ([]uint64, error) {
+	fromStr, toStr, isRange := strings.Cut(strings.TrimSpace(spec), "-")
+	from, err := strconv.ParseUint(strings.TrimSpace(fromStr), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid epoch %q: %w", spec, err)
+	}
+	to := from
+	if isRange {
+		to, err = strconv.ParseUint(strings.TrimSpace(toStr), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid epoch range %q: %w", spec, err)
+		}
+	}
+	if to < from {
+		return nil, fmt.Errorf("invalid epoch range %q: end is before start", spec)
+	}
+	if to-from >= maxRangeLen {
+		return nil, fmt.Errorf("invalid epoch range %q: covers more than %d epochs", spec, maxRangeLen)
+	}
+
+	epochs := make([]uint64, 0, to-from+1)
+	for n := from; n <= to; n++ {
+		epochs = append(epochs, n)
+	}
+	return epochs, nil
+}
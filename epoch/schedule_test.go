@@ -0,0 +1,122 @@
+package epoch
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func testSchedule(t *testing.T) Schedule {
+	t.Helper()
+	s, err := NewSchedule(1_000_000, 3600)
+	if err != nil {
+		t.Fatalf("NewSchedule: %v", err)
+	}
+	return s
+}
+
+func TestAt(t *testing.T) {
+	s := testSchedule(t)
+	start := s.Start
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want uint64
+	}{
+		{"before start", start.Add(-time.Hour), 0},
+		{"at start", start, 0},
+		{"inside epoch 0", start.Add(59 * time.Minute), 0},
+		{"last instant of epoch 0", start.Add(time.Hour - time.Nanosecond), 0},
+		{"start of epoch 1", start.Add(time.Hour), 1},
+		{"inside epoch 41", start.Add(41*time.Hour + 30*time.Minute), 41},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.At(tt.t); got != tt.want {
+				t.Errorf("At(%s) = %d, want %d", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	s := testSchedule(t)
+	offset := 10 * time.Minute
+	runAt := func(n uint64) time.Time { return s.StartOf(n).Add(offset) }
+
+	tests := []struct {
+		name      string
+		after     time.Time
+		wantEpoch uint64
+	}{
+		{"before start", s.Start.Add(-time.Hour), 0},
+		{"at start, before offset", s.Start, 0},
+		{"just before run time", runAt(3).Add(-time.Nanosecond), 3},
+		{"exactly at run time", runAt(3), 4},
+		{"just after run time", runAt(3).Add(time.Nanosecond), 4},
+		{"at epoch boundary", s.StartOf(4), 4},
+		{"last instant of epoch", s.EndOf(4).Add(-time.Nanosecond), 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, n := s.NextRun(tt.after, offset)
+			if n != tt.wantEpoch || !next.Equal(runAt(tt.wantEpoch)) {
+				t.Errorf("NextRun(%s) = %s, epoch %d; want %s, epoch %d", tt.after, next, n, runAt(tt.wantEpoch), tt.wantEpoch)
+			}
+			if !next.After(tt.after) {
+				t.Errorf("NextRun(%s) = %s, not after it", tt.after, next)
+			}
+		})
+	}
+
+	// With no offset the run time is the epoch boundary itself.
+	if next, n := s.NextRun(s.StartOf(2), 0); n != 3 || !next.Equal(s.StartOf(3)) {
+		t.Errorf("NextRun(start of 2, 0) = %s, epoch %d; want start of 3", next, n)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []uint64 // nil: only the length is checked
+		wantLen int
+		wantErr bool
+	}{
+		{spec: "5", want: []uint64{5}},
+		{spec: " 7 ", want: []uint64{7}},
+		{spec: "3-6", want: []uint64{3, 4, 5, 6}},
+		{spec: "4 - 4", want: []uint64{4}},
+		{spec: "0-999", wantLen: maxRangeLen},
+		{spec: "0-1000", wantErr: true},
+		{spec: "6-3", wantErr: true},
+		{spec: "", wantErr: true},
+		{spec: "-3", wantErr: true},
+		{spec: "3-", wantErr: true},
+		{spec: "a", wantErr: true},
+		{spec: "1-2-3", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseRange(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseRange(%q) = %v, want an error", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRange(%q): %v", tt.spec, err)
+			}
+			if tt.want == nil {
+				if len(got) != tt.wantLen {
+					t.Errorf("ParseRange(%q) covers %d epochs, want %d", tt.spec, len(got), tt.wantLen)
+				}
+				return
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseRange(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
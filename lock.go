@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"uptime-service/config"
+	"uptime-service/db"
+)
+
+// runLockName is the run lock shared by every command that sends
+// transactions or writes proofs. They all send from the same key and update
+// the same rows, so any two of them running at once can collide, not just
+// two copies of one command.
+const runLockName = "submit"
+
+// lockedCommands are the commands that take the run lock. The daemon takes
+// it per cycle instead, `migrate up` and `migrate down` take it in
+// runMigrate, and read-only commands don't take it at all.
+var lockedCommands = map[string]bool{
+	"generate-and-submit":          true,
+	"submit-validator":             true,
+	"submit-missing-uptime-proofs": true,
+	"resolve-rewards":              true,
+	"resolve-validator":            true,
+}
+
+// takesRunLock reports whether cmd takes the run lock. Of the proofs
+// subcommands only import does, since it writes proofs.
+func takesRunLock(cmd string, args commandArgs) bool {
+	if cmd == "proofs" {
+		return len(args.positional) > 0 && args.positional[0] == "import"
+	}
+	return lockedCommands[cmd]
+}
+
+// acquireRunLock takes the run lock for cmd on the configured network,
+// waiting up to wait for another holder to finish. Dry runs change nothing,
+// so they skip the lock. The returned release func is always safe to call.
+func acquireRunLock(
+	ctx context.Context,
+	cfg *config.Config,
+	locker db.RunLocker,
+	cmd string,
+	wait time.Duration,
+) (release func(), err error) {
+	if cfg.DryRun {
+		return func() {}, nil
+	}
+
+	host, _ := os.Hostname()
+	holder := fmt.Sprintf("uptime-service %s on %s (pid %d)", cmd, host, os.Getpid())
+	lock, err := locker.AcquireRunLock(ctx, runLockName, uint32(cfg.NetworkID), holder, wait)
+	if err != nil {
+		return func() {}, err
+	}
+	return lock.Release, nil
+}
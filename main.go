@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"uptime-service/config"
 	"uptime-service/db"
-	"uptime-service/delegation"
+	"uptime-service/epoch"
 	"uptime-service/logging"
 	"uptime-service/service"
 )
@@ -18,6 +20,9 @@ import (
 func main() {
 	// Global flags
 	configPath := flag.String("config", "config.json", "Path to config file")
+	dryRun := flag.Bool("dry-run", false, "Simulate transactions instead of broadcasting them and skip DB writes")
+	timeout := flag.Duration("timeout", 0, "Cancel the command after this long, e.g. 2h (0 = no limit)")
+	lockWait := flag.Duration("lock-wait", 0, "Wait this long for another run holding the run lock, e.g. 10m (0 = exit at once)")
 	flag.Parse()
 
 	if flag.NArg() == 0 {
@@ -25,21 +30,48 @@ func main() {
 	}
 	cmd := flag.Arg(0)
 
+	args, err := parseCommandArgs(cmd, flag.Args()[1:])
+	if err != nil {
+		printUsageAndExit(err.Error())
+	}
+
 	// Load config
 	cfg, err := config.LoadConfig(*configPath)
 	if err != nil {
 		log.Fatalf("failed to load config: %v", err)
 	}
 
+	if *dryRun {
+		cfg.DryRun = true
+	}
+
 	// Configure logging
 	logging.SetLevel(cfg.LogLevel)
+	if cfg.DryRun {
+		logging.Info("DRY RUN: transactions will be simulated, not broadcast, and DB writes skipped")
+	}
 
-	// Init DB store
-	//
-	// NOTE: if your DB constructor is still called NewDBClient, either:
-	//   - rename it to NewUptimeStore and return *UptimeStore
-	//   - or change this line accordingly.
-	store, err := db.NewUptimeStore(cfg.DatabaseURL)
+	// SIGINT/SIGTERM (and -timeout) cancel ctx, which every network call
+	// and DB query observes, so a stuck run stops cleanly.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	// Migrations run against a schema the store would refuse, so they are
+	// dispatched before anything else touches the database.
+	if cmd == "migrate" {
+		if err := runMigrate(ctx, cfg, args, *lockWait); err != nil {
+			log.Fatalf("command %s failed: %v", cmd, err)
+		}
+		return
+	}
+
+	// Init DB store: Postgres, or SQLite for a sqlite: database_url
+	store, err := db.Open(ctx, cfg.DatabaseURL, cfg.DryRun)
 	if err != nil {
 		log.Fatalf("failed to initialize database: %v", err)
 	}
@@ -55,19 +87,56 @@ func main() {
 		log.Fatalf("failed to initialize uptime service: %v", err)
 	}
 
-	ctx := context.Background()
+	// Commands that send transactions or write proofs hold the run lock for
+	// their whole duration, so redundant schedulers can't overlap.
+	if takesRunLock(cmd, args) {
+		release, err := acquireRunLock(ctx, cfg, store, cmd, *lockWait)
+		if err != nil {
+			log.Fatalf("command %s not started: %v", cmd, err)
+		}
+		defer release()
+	}
+
 	start := time.Now()
 
 	// Dispatch command
 	switch cmd {
 	case "resolve-rewards":
-		err = uptimeSvc.ResolveRewards(ctx)
+		var epochNum uint64
+		epochNum, err = rewardsEpoch(ctx, cmd, args, uptimeSvc)
+		if err == nil {
+			err = uptimeSvc.ResolveRewards(ctx, epochNum)
+		}
+
+	case "resolve-validator":
+		validationID := args.validationID(cmd)
+		var epochNum uint64
+		epochNum, err = rewardsEpoch(ctx, cmd, args, uptimeSvc)
+		if err == nil {
+			err = uptimeSvc.ResolveValidatorRewards(ctx, validationID, epochNum)
+		}
 
 	case "generate-and-submit":
 		err = uptimeSvc.GenerateAndSubmitUptimeProofs(ctx)
 
+	case "submit-validator":
+		err = uptimeSvc.GenerateAndSubmitForValidator(ctx, args.validationID(cmd))
+
 	case "submit-missing-uptime-proofs":
-		err = service.SubmitMissingUptimeProofs(ctx, cfg, store)
+		var epochs []uint64
+		epochs, err = commandEpochs(ctx, args, uptimeSvc)
+		if err == nil {
+			err = uptimeSvc.SubmitMissingUptimeProofs(ctx, epochs)
+		}
+
+	case "daemon":
+		err = runDaemon(ctx, cfg, store, uptimeSvc)
+
+	case "proofs":
+		err = runProofs(ctx, cfg, store, args)
+
+	case "runs":
+		err = runRuns(ctx, store, args)
 
 	default:
 		printUsageAndExit(fmt.Sprintf("unknown command: %s", cmd))
@@ -86,63 +155,161 @@ func printUsageAndExit(msg string) {
 		fmt.Fprintf(os.Stderr, "%s\n\n", msg)
 	}
 	fmt.Fprintln(os.Stderr, `Usage:
-  uptime-service -config=config.json <command> [args]
+  uptime-service -config=config.json [-dry-run] [-timeout=2h] [-lock-wait=10m] <command> [args]
 
   Commands:
     resolve-rewards               Resolve rewards for all validators with proofs
+                                  [-epoch N] (default: last completed epoch)
+    resolve-validator <id>        Resolve rewards for one validator [-epoch N]
     generate-and-submit           End-to-end: fetch → sign → submit → store
-    submit-missing-uptime-proofs  Re-submit missing/expired proofs for an epoch`)
+    submit-validator <id>         fetch → sign → submit → store for one validator
+    submit-missing-uptime-proofs  Re-submit missing/expired proofs for an epoch
+                                  [-epoch N | -epoch N-M] (default: current epoch)
+    daemon                        Run all of the above once per staking epoch until stopped
+    proofs history <id>           Print every recorded proof submission for a validator
+                                  [-epoch N] (default: all epochs)
+    proofs export <file>          Write the latest stored proof of every validator to a file
+                                  [-format jsonl|csv] (default: from the file extension)
+    proofs import <file>          Store the proofs in an exported file, keeping any stored
+                                  proof with a higher uptime [-format jsonl|csv]
+    runs list                     List recent command runs [-limit N] (default: 20)
+    runs show <id>                Show a run's status, errors and per-validator outcomes
+    migrate up|down|status        Apply pending schema migrations, roll back the
+                                  latest one, or list them [-force] (down only:
+                                  needed to roll back the first migration)`)
 	os.Exit(1)
 }
 
-// resolveSingleValidator replicates your old "submit-single" behavior:
-// - look up uptime for a specific validation ID from DB
-// - fetch delegations
-// - call resolveRewards for that one validator.
-func resolveSingleValidator(
-	_ context.Context,
-	cfg *config.Config,
-	store *db.UptimeStore,
-	validationID string,
-) error {
-	proofs, err := store.GetAllUptimeProofs()
-	if err != nil {
-		return fmt.Errorf("failed to query proofs: %w", err)
-	}
+// commandArgs holds what follows the command name on the command line.
+type commandArgs struct {
+	epoch      string   // -epoch value: "N" or an inclusive range "N-M"; "" if absent
+	limit      int      // -limit value for listings
+	format     string   // -format value for proof files: "jsonl", "csv" or "" to infer
+	force      bool     // -force: allow `migrate down` to roll back the first migration
+	positional []string // non-flag arguments, e.g. a validation ID
+}
+
+// commandFlags lists the flags each command takes, keyed by the command
+// and, for commands with subcommands, the subcommand. Any other flag is
+// rejected rather than silently ignored.
+var commandFlags = map[string][]string{
+	"resolve-rewards":              {"epoch"},
+	"resolve-validator":            {"epoch"},
+	"submit-missing-uptime-proofs": {"epoch"},
+	"proofs history":               {"epoch"},
+	"proofs export":                {"format"},
+	"proofs import":                {"format"},
+	"runs list":                    {"limit"},
+	"migrate down":                 {"force"},
+}
+
+// subcommandCommands are the commands whose first positional argument is a
+// subcommand with flags of its own.
+var subcommandCommands = map[string]bool{"proofs": true, "runs": true, "migrate": true}
+
+// parseCommandArgs parses a command's own flags. Flags may appear before or
+// after positional arguments, and must be ones the command takes.
+func parseCommandArgs(cmd string, args []string) (commandArgs, error) {
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	epochArg := fs.String("epoch", "", "Epoch N or inclusive range N-M")
+	limitArg := fs.Int("limit", 20, "Maximum number of rows to list")
+	formatArg := fs.String("format", "", "Proof file format: jsonl or csv (default: from the file extension)")
+	forceArg := fs.Bool("force", false, "Allow rolling back the first migration")
 
-	proof, ok := proofs[validationID]
-	if !ok {
-		return fmt.Errorf("validation ID %s not found in DB", validationID)
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return commandArgs{}, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
 	}
 
-	logging.Infof("found DB entry with uptime = %d for %s", proof.UptimeSeconds, validationID)
+	name := cmd
+	if subcommandCommands[cmd] && len(positional) > 0 {
+		name = cmd + " " + positional[0]
+	}
+	allowed := make(map[string]bool)
+	for _, f := range commandFlags[name] {
+		allowed[f] = true
+	}
+	var unknown error
+	fs.Visit(func(f *flag.Flag) {
+		if unknown == nil && !allowed[f.Name] {
+			unknown = fmt.Errorf("%s does not take -%s", name, f.Name)
+		}
+	})
+	if unknown != nil {
+		return commandArgs{}, unknown
+	}
 
-	delegationClient, err := delegation.NewClient(
-		cfg.GraphQLEndpoint,
-		cfg.BeamRPC,
-		cfg.StakingManagerAddress,
-		cfg.PrivateKey,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to init delegation client: %w", err)
+	if *limitArg < 1 {
+		return commandArgs{}, fmt.Errorf("-limit must be at least 1")
 	}
+	return commandArgs{
+		epoch:      *epochArg,
+		limit:      *limitArg,
+		format:     *formatArg,
+		force:      *forceArg,
+		positional: positional,
+	}, nil
+}
 
-	delegations, err := delegationClient.GetDelegationsForValidator(validationID)
-	if err != nil {
-		return fmt.Errorf("failed to fetch delegations: %w", err)
+// validationID returns the single validation ID argument of cmd, exiting
+// with usage if it is missing.
+func (a commandArgs) validationID(cmd string) string {
+	if len(a.positional) != 1 {
+		printUsageAndExit(fmt.Sprintf("%s takes exactly one validation ID", cmd))
 	}
+	return a.positional[0]
+}
 
-	if len(delegations) == 0 {
-		logging.Infof("no delegations found for validator %s", validationID)
-		return nil
+// commandEpochs returns the epochs given with -epoch, falling back to the
+// current epoch derived from the staking manager's schedule.
+func commandEpochs(
+	ctx context.Context,
+	args commandArgs,
+	uptimeSvc *service.UptimeService,
+) ([]uint64, error) {
+	if args.epoch != "" {
+		return epoch.ParseRange(args.epoch)
 	}
 
-	logging.Infof("found %d delegations for validator %s", len(delegations), validationID)
+	current, err := uptimeSvc.CurrentEpoch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("derive current epoch: %w", err)
+	}
+	logging.Infof("no -epoch given, using current epoch %d", current)
+	return []uint64{current}, nil
+}
 
-	if err := delegationClient.ResolveRewards(delegations); err != nil {
-		return fmt.Errorf("failed to resolve rewards: %w", err)
+// rewardsEpoch returns the single epoch given with -epoch, falling back to
+// the last completed epoch: rewards can only be resolved once an epoch ends.
+func rewardsEpoch(
+	ctx context.Context,
+	cmd string,
+	args commandArgs,
+	uptimeSvc *service.UptimeService,
+) (uint64, error) {
+	if args.epoch != "" {
+		epochs, err := epoch.ParseRange(args.epoch)
+		if err != nil {
+			return 0, err
+		}
+		if len(epochs) > 1 {
+			return 0, fmt.Errorf("%s takes a single epoch, not a range", cmd)
+		}
+		return epochs[0], nil
 	}
 
-	logging.Infof("✓ successfully resolved rewards for %s", validationID)
-	return nil
+	last, err := uptimeSvc.LastCompletedEpoch(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("derive last completed epoch: %w", err)
+	}
+	logging.Infof("no -epoch given, using last completed epoch %d", last)
+	return last, nil
 }
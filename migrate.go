@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"uptime-service/config"
+	"uptime-service/db"
+	"uptime-service/logging"
+)
+
+// runMigrate dispatches the `migrate` subcommands. It runs before the store
+// and service are built, since db.Open refuses a schema that is behind. up
+// and down hold the run lock, waiting up to lockWait, so the schema doesn't
+// change under a command that is writing.
+func runMigrate(ctx context.Context, cfg *config.Config, args commandArgs, lockWait time.Duration) error {
+	if len(args.positional) != 1 {
+		printUsageAndExit("migrate takes exactly one of up, down or status")
+	}
+	sub := args.positional[0]
+	if sub != "up" && sub != "down" && sub != "status" {
+		printUsageAndExit(fmt.Sprintf("unknown migrate subcommand: %s", sub))
+	}
+
+	migrator, err := db.NewMigrator(ctx, cfg.DatabaseURL, cfg.DryRun)
+	if err != nil {
+		return fmt.Errorf("init migrator: %w", err)
+	}
+	defer func() {
+		if cerr := migrator.Close(); cerr != nil {
+			logging.Errorf("failed to close database: %v", cerr)
+		}
+	}()
+
+	if sub != "status" {
+		release, err := acquireRunLock(ctx, cfg, migrator, "migrate "+sub, lockWait)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	switch sub {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, mig := range applied {
+			logging.Infof("✅ migration %04d_%s applied", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			logging.Info("schema is up to date, nothing to apply")
+		}
+		return nil
+
+	case "down":
+		mig, err := migrator.Down(ctx, args.force)
+		if err != nil {
+			return err
+		}
+		if mig == nil {
+			logging.Info("no migrations applied, nothing to roll back")
+			return nil
+		}
+		logging.Infof("✅ migration %04d_%s rolled back", mig.Version, mig.Name)
+		return nil
+
+	default:
+		return printMigrationStatus(ctx, migrator)
+	}
+}
+
+// printMigrationStatus writes every migration and when it was applied to
+// stdout.
+func printMigrationStatus(ctx context.Context, migrator *db.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, st := range statuses {
+		applied := "pending"
+		if st.AppliedAt.Valid {
+			applied = st.AppliedAt.Time.UTC().Format(time.RFC3339)
+		}
+		if !st.Known {
+			applied += " (not in this build)"
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", st.Version, st.Name, applied)
+	}
+	return w.Flush()
+}
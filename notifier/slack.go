@@ -2,6 +2,7 @@ package notifier
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -31,7 +32,7 @@ func (s *Slack) Enabled() bool {
 // Post sends text as a Slack message. Errors are returned to the caller —
 // callers should log-and-continue, never abort a run because of a failed
 // notification.
-func (s *Slack) Post(text string) error {
+func (s *Slack) Post(ctx context.Context, text string) error {
 	if !s.Enabled() {
 		return nil
 	}
@@ -39,7 +40,13 @@ func (s *Slack) Post(text string) error {
 	if err != nil {
 		return fmt.Errorf("marshal slack payload: %w", err)
 	}
-	resp, err := s.client.Post(s.webhookURL, "application/json", bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("post slack: %w", err)
 	}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"uptime-service/aggregator"
+	"uptime-service/config"
+	"uptime-service/db"
+	"uptime-service/epoch"
+	"uptime-service/logging"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+)
+
+// runProofs dispatches the `proofs` subcommands, which inspect the local
+// proof store and move its latest proofs in and out of files.
+func runProofs(ctx context.Context, cfg *config.Config, store db.Store, args commandArgs) error {
+	if len(args.positional) == 0 {
+		printUsageAndExit("proofs needs a subcommand")
+	}
+
+	sub := args.positional[0]
+	args.positional = args.positional[1:]
+	switch sub {
+	case "history":
+		return printProofHistory(ctx, store, args)
+	case "export":
+		return exportProofs(ctx, store, args)
+	case "import":
+		return importProofs(ctx, cfg, store, args)
+	default:
+		printUsageAndExit(fmt.Sprintf("unknown proofs subcommand: %s", sub))
+	}
+	return nil
+}
+
+// printProofHistory writes every recorded submission for one validator to
+// stdout, oldest first, optionally limited to the epoch given with -epoch.
+func printProofHistory(ctx context.Context, store db.Store, args commandArgs) error {
+	validationID, err := ids.FromString(args.validationID("proofs history"))
+	if err != nil {
+		return fmt.Errorf("invalid validation ID: %w", err)
+	}
+
+	var epochFilter sql.NullInt64
+	if args.epoch != "" {
+		epochs, err := epoch.ParseRange(args.epoch)
+		if err != nil {
+			return err
+		}
+		if len(epochs) > 1 {
+			return fmt.Errorf("proofs history takes a single epoch, not a range")
+		}
+		epochFilter = sql.NullInt64{Int64: int64(epochs[0]), Valid: true}
+	}
+
+	entries, err := store.GetProofHistory(ctx, validationID, epochFilter)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Printf("no recorded proofs for %s\n", validationID)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RECORDED\tEPOCH\tUPTIME\tSTATUS\tTX\tERROR")
+	for _, e := range entries {
+		epochCol := "-"
+		if e.Epoch.Valid {
+			epochCol = strconv.FormatInt(e.Epoch.Int64, 10)
+		}
+		txCol := e.TxHash
+		if txCol == "" {
+			txCol = "-"
+		}
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%d\t%s\t%s\t%s\n",
+			e.RecordedAt.UTC().Format(time.RFC3339),
+			epochCol,
+			e.UptimeSeconds,
+			e.Status,
+			txCol,
+			e.Error,
+		)
+	}
+	return w.Flush()
+}
+
+// proofRecord is one uptime proof in an export file. The hex ID is the form
+// the contracts and the subgraph use. UpdatedAt is informational: import
+// ignores it, since storing a proof always stamps the current time.
+type proofRecord struct {
+	ValidationID    string `json:"validation_id"`
+	ValidationIDHex string `json:"validation_id_hex"`
+	UptimeSeconds   uint64 `json:"uptime_seconds"`
+	SignedMessage   string `json:"signed_message"` // base64
+	UpdatedAt       string `json:"updated_at,omitempty"`
+}
+
+// proofCSVHeader is the header row of a CSV export, in column order.
+var proofCSVHeader = []string{"validation_id", "validation_id_hex", "uptime_seconds", "signed_message", "updated_at"}
+
+const (
+	formatJSONL = "jsonl"
+	formatCSV   = "csv"
+)
+
+// proofFile returns the single file argument of cmd and its format: the
+// -format flag if given, else csv for a .csv file and jsonl otherwise.
+func proofFile(cmd string, args commandArgs) (string, string, error) {
+	if len(args.positional) != 1 {
+		printUsageAndExit(fmt.Sprintf("%s takes exactly one file", cmd))
+	}
+	path := args.positional[0]
+
+	switch args.format {
+	case formatJSONL, formatCSV:
+		return path, args.format, nil
+	case "":
+		if strings.EqualFold(filepath.Ext(path), ".csv") {
+			return path, formatCSV, nil
+		}
+		return path, formatJSONL, nil
+	default:
+		return "", "", fmt.Errorf("unknown format %q, want %s or %s", args.format, formatJSONL, formatCSV)
+	}
+}
+
+// exportProofs writes every stored latest proof to a file, sorted by
+// validation ID.
+func exportProofs(ctx context.Context, store db.Store, args commandArgs) (err error) {
+	path, format, err := proofFile("proofs export", args)
+	if err != nil {
+		return err
+	}
+
+	proofs, err := store.GetAllUptimeProofs(ctx)
+	if err != nil {
+		return err
+	}
+	records := make([]proofRecord, 0, len(proofs))
+	for _, proof := range proofs {
+		records = append(records, proofRecord{
+			ValidationID:    proof.ValidationID.String(),
+			ValidationIDHex: "0x" + proof.ValidationID.Hex(),
+			UptimeSeconds:   proof.UptimeSeconds,
+			SignedMessage:   base64.StdEncoding.EncodeToString(proof.SignedMessage.Bytes()),
+			UpdatedAt:       proof.UpdatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ValidationID < records[j].ValidationID })
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create export file: %w", err)
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("close export file: %w", cerr)
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	if format == formatCSV {
+		err = writeProofsCSV(w, records)
+	} else {
+		err = writeProofsJSONL(w, records)
+	}
+	if err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+
+	logging.Infof("✅ exported %d proofs to %s (%s)", len(records), path, format)
+	return nil
+}
+
+func writeProofsJSONL(w io.Writer, records []proofRecord) error {
+	enc := json.NewEncoder(w)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeProofsCSV(w io.Writer, records []proofRecord) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(proofCSVHeader); err != nil {
+		return err
+	}
+	for _, rec := range records {
+		if err := cw.Write([]string{
+			rec.ValidationID,
+			rec.ValidationIDHex,
+			strconv.FormatUint(rec.UptimeSeconds, 10),
+			rec.SignedMessage,
+			rec.UpdatedAt,
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// importProofs stores the proofs in a file in one transaction, so a bad
+// file or a failed write imports nothing. Each proof goes through
+// StoreUptimeProof's rules: a proof below the stored uptime is skipped,
+// keeping the stored one, and one equal to it replaces the signed message.
+func importProofs(ctx context.Context, cfg *config.Config, store db.Store, args commandArgs) error {
+	path, format, err := proofFile("proofs import", args)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open import file: %w", err)
+	}
+	defer f.Close()
+
+	var records []proofRecord
+	if format == formatCSV {
+		records, err = readProofsCSV(f)
+	} else {
+		records, err = readProofsJSONL(f)
+	}
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+
+	proofs := make([]db.UptimeProof, 0, len(records))
+	for i, rec := range records {
+		proof, err := decodeProofRecord(cfg, rec)
+		if err != nil {
+			return fmt.Errorf("record %d (%s) of %s: %w", i+1, rec.ValidationID, path, err)
+		}
+		proofs = append(proofs, proof)
+	}
+
+	stored, kept, err := store.ImportUptimeProofs(ctx, proofs)
+	if err != nil {
+		return fmt.Errorf("import %s: %w", path, err)
+	}
+
+	logging.Infof("✅ imported %d proofs from %s, kept %d stored proofs with a higher uptime", stored, path, kept)
+	return nil
+}
+
+func readProofsJSONL(r io.Reader) ([]proofRecord, error) {
+	var records []proofRecord
+	dec := json.NewDecoder(r)
+	for {
+		var rec proofRecord
+		if err := dec.Decode(&rec); errors.Is(err, io.EOF) {
+			return records, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(records)+1, err)
+		}
+		records = append(records, rec)
+	}
+}
+
+// readProofsCSV reads a CSV export. Columns are found by their header, so
+// their order doesn't matter; validation_id_hex and updated_at may be left
+// out.
+func readProofsCSV(r io.Reader) ([]proofRecord, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	col := make(map[string]int, len(header))
+	for i, name := range header {
+		col[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{"validation_id", "uptime_seconds", "signed_message"} {
+		if _, ok := col[name]; !ok {
+			return nil, fmt.Errorf("missing column %s", name)
+		}
+	}
+	field := func(row []string, name string) string {
+		if i, ok := col[name]; ok {
+			return row[i]
+		}
+		return ""
+	}
+
+	var records []proofRecord
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		uptime, err := strconv.ParseUint(field(row, "uptime_seconds"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: invalid uptime_seconds: %w", len(records)+1, err)
+		}
+		records = append(records, proofRecord{
+			ValidationID:    field(row, "validation_id"),
+			ValidationIDHex: field(row, "validation_id_hex"),
+			UptimeSeconds:   uptime,
+			SignedMessage:   field(row, "signed_message"),
+			UpdatedAt:       field(row, "updated_at"),
+		})
+	}
+}
+
+// decodeProofRecord checks rec and decodes its signed message. The message
+// must attest to the record's validation ID and uptime and come from the
+// configured network and source chain, so proofs from another environment
+// aren't imported by mistake. Signatures are checked on-chain at
+// submission, not here.
+func decodeProofRecord(cfg *config.Config, rec proofRecord) (db.UptimeProof, error) {
+	validationID, err := ids.FromString(rec.ValidationID)
+	if err != nil {
+		return db.UptimeProof{}, fmt.Errorf("invalid validation ID: %w", err)
+	}
+	if rec.ValidationIDHex != "" &&
+		strings.TrimPrefix(strings.ToLower(rec.ValidationIDHex), "0x") != validationID.Hex() {
+		return db.UptimeProof{}, fmt.Errorf("hex ID %s does not match %s", rec.ValidationIDHex, validationID)
+	}
+
+	msgBytes, err := base64.StdEncoding.DecodeString(rec.SignedMessage)
+	if err != nil {
+		return db.UptimeProof{}, fmt.Errorf("decode signed message: %w", err)
+	}
+	msg, err := warp.ParseMessage(msgBytes)
+	if err != nil {
+		return db.UptimeProof{}, fmt.Errorf("parse signed message: %w", err)
+	}
+
+	if msg.NetworkID != uint32(cfg.NetworkID) {
+		return db.UptimeProof{}, fmt.Errorf("message is for network %d, not %d", msg.NetworkID, cfg.NetworkID)
+	}
+	if msg.SourceChainID.String() != cfg.SourceChainId {
+		return db.UptimeProof{}, fmt.Errorf("message is from chain %s, not %s", msg.SourceChainID, cfg.SourceChainId)
+	}
+	msgID, msgUptime, err := aggregator.ParseValidationUptimeMessage(msg)
+	if err != nil {
+		return db.UptimeProof{}, err
+	}
+	if msgID != validationID || msgUptime != rec.UptimeSeconds {
+		return db.UptimeProof{}, fmt.Errorf(
+			"message attests uptime %d for %s, not %d",
+			msgUptime,
+			msgID,
+			rec.UptimeSeconds,
+		)
+	}
+
+	return db.UptimeProof{ValidationID: validationID, UptimeSeconds: rec.UptimeSeconds, SignedMessage: msg}, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"uptime-service/db"
+)
+
+// runRuns dispatches the `runs` subcommands, which read back the recorded
+// command runs.
+func runRuns(ctx context.Context, store db.Store, args commandArgs) error {
+	if len(args.positional) == 0 {
+		printUsageAndExit("runs needs a subcommand")
+	}
+
+	switch sub := args.positional[0]; sub {
+	case "list":
+		if len(args.positional) != 1 {
+			printUsageAndExit("runs list takes no arguments")
+		}
+		return printRuns(ctx, store, args.limit)
+	case "show":
+		if len(args.positional) != 2 {
+			printUsageAndExit("runs show takes exactly one run ID")
+		}
+		id, err := strconv.ParseInt(args.positional[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid run ID %q: %w", args.positional[1], err)
+		}
+		return printRun(ctx, store, id)
+	default:
+		printUsageAndExit(fmt.Sprintf("unknown runs subcommand: %s", sub))
+	}
+	return nil
+}
+
+// printRuns writes the latest limit runs to stdout, newest first.
+func printRuns(ctx context.Context, store db.Store, limit int) error {
+	runs, err := store.ListRuns(ctx, limit)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Println("no recorded runs")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTARTED\tDURATION\tSTATUS\tCOMMAND")
+	for _, run := range runs {
+		fmt.Fprintf(
+			w,
+			"%d\t%s\t%s\t%s\t%s\n",
+			run.ID,
+			run.StartedAt.UTC().Format(time.RFC3339),
+			runDuration(run),
+			run.Status,
+			run.Command,
+		)
+	}
+	return w.Flush()
+}
+
+// printRun writes one run and its per-validator outcomes to stdout.
+func printRun(ctx context.Context, store db.Store, id int64) error {
+	run, validators, err := store.GetRun(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Run %d: %s\n", run.ID, run.Command)
+	fmt.Printf("Status:      %s\n", run.Status)
+	fmt.Printf("Started:     %s\n", run.StartedAt.UTC().Format(time.RFC3339))
+	fmt.Printf("Duration:    %s\n", runDuration(run))
+	fmt.Printf("Config hash: %s\n", run.ConfigHash)
+	if run.Error != "" {
+		fmt.Printf("Error:       %s\n", run.Error)
+	}
+	if len(validators) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VALIDATION ID\tOUTCOME\tERROR")
+	for _, v := range validators {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", v.ValidationID, v.Outcome, v.Error)
+	}
+	return w.Flush()
+}
+
+// runDuration is how long run took, or "-" if it never finished.
+func runDuration(run db.Run) string {
+	if !run.FinishedAt.Valid {
+		return "-"
+	}
+	return run.FinishedAt.Time.Sub(run.StartedAt).Round(time.Second).String()
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"uptime-service/config"
+	"uptime-service/db"
+	"uptime-service/logging"
+)
+
+// runLog records one command run in the runs table: it is started when the
+// command begins, collects an outcome per validator, and is finished with
+// the command's error. Recording failures are only logged; a run must not
+// fail because its own bookkeeping did.
+type runLog struct {
+	store      db.Store
+	id         int64 // 0 when the run is not being recorded
+	command    string
+	validators []db.RunValidator
+}
+
+// startRun records the start of command.
+func startRun(ctx context.Context, store db.Store, cfg *config.Config, command string) *runLog {
+	r := &runLog{store: store, command: command}
+
+	id, err := store.StartRun(ctx, command, cfg.Hash())
+	if err != nil {
+		logging.Errorf("failed to record start of %s run: %v", command, err)
+		return r
+	}
+	if id != 0 {
+		logging.Infof("recording this %s run as run %d", command, id)
+	}
+	r.id = id
+	return r
+}
+
+// add notes what the run did for validationID. A non-nil err marks the
+// validator as failed.
+func (r *runLog) add(validationID, outcome string, err error) {
+	v := db.RunValidator{ValidationID: validationID, Outcome: outcome}
+	if err != nil {
+		v.Error = err.Error()
+	}
+	r.validators = append(r.validators, v)
+}
+
+// finish stores the run's final status: interrupted or failed if runErr says
+// so, partial if any validator failed, succeeded otherwise.
+func (r *runLog) finish(ctx context.Context, runErr error) {
+	if r.id == 0 {
+		return
+	}
+
+	status := db.RunSucceeded
+	var errMsg string
+	switch {
+	case errors.Is(runErr, context.Canceled) || errors.Is(runErr, context.DeadlineExceeded):
+		status, errMsg = db.RunInterrupted, runErr.Error()
+	case runErr != nil:
+		status, errMsg = db.RunFailed, runErr.Error()
+	default:
+		for _, v := range r.validators {
+			if v.Error != "" {
+				status = db.RunPartial
+				break
+			}
+		}
+	}
+
+	if err := r.store.FinishRun(context.WithoutCancel(ctx), r.id, status, errMsg, r.validators); err != nil {
+		logging.Errorf("failed to record end of %s run %d: %v", r.command, r.id, err)
+	}
+}
+
+// addOutcome adds every validator of a generate-and-submit run. A validator
+// that was submitted but failed to store appears as failed_store.
+func (r *runLog) addOutcome(o runOutcome) {
+	buckets := []struct {
+		outcome string
+		ids     []string
+	}{
+		{"submitted", o.submitted},
+		{"up_to_date", o.upToDate},
+		{"no_samples", o.noSamples},
+		{"failed_sign", o.failedSign},
+		{"failed_submit", o.failedSubmit},
+		{"reverted", o.reverted},
+		{"unconfirmed", o.unconfirmed},
+		{"failed_store", o.failedStore},
+	}
+
+	index := make(map[string]int)
+	for _, b := range buckets {
+		for _, validationID := range b.ids {
+			v := db.RunValidator{ValidationID: validationID, Outcome: b.outcome, Error: o.errs[validationID]}
+			if b.outcome == "failed_sign" && v.Error == "" {
+				v.Error = "no candidate uptime was signed by quorum"
+			}
+			if i, ok := index[validationID]; ok {
+				r.validators[i] = v
+				continue
+			}
+			index[validationID] = len(r.validators)
+			r.validators = append(r.validators, v)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"uptime-service/aggregator"
+	"uptime-service/logging"
+
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+)
+
+// uptimeSearchLimits bound searchSignableUptime: it stops once the highest
+// signable uptime is known to within resolution seconds, and never makes
+// more than maxAttempts signature requests.
+type uptimeSearchLimits struct {
+	resolution  uint64
+	maxAttempts int
+}
+
+// searchSignableUptime finds the highest uptime trySign gets signed, given
+// uptime samples in descending order and the uptime already stored for the
+// validator (0 if none).
+//
+// It first walks the samples from highest to lowest. The first sample that
+// signs is the highest known-good value and the sample above it, if any, the
+// lowest known-bad one. When every sample fails, the lowest sample is the
+// known-bad bound and the floor is 0, raised to the stored uptime if that
+// still signs. Without an upper bound it probes upward in doubling steps
+// until a value fails.
+// It then binary-searches between the two bounds until they are within the
+// resolution. Every request, samples included, counts against maxAttempts;
+// once they are used up the best signed value so far is returned. If ctx is
+// cancelled or a request fails for any reason other than the validators
+// declining to sign (aggregator.ErrSigningFailed), the search is abandoned
+// and nothing is returned.
+func searchSignableUptime(
+	ctx context.Context,
+	validationID string,
+	samples []uint64,
+	storedUptime uint64,
+	limits uptimeSearchLimits,
+	trySign func(uptime uint64) (*warp.Message, error),
+) (uint64, *warp.Message) {
+	resolution := max(limits.resolution, 1)
+	attemptsLeft := limits.maxAttempts
+	sign := func(uptime uint64) (*warp.Message, error) {
+		attemptsLeft--
+		return trySign(uptime)
+	}
+
+	// good/bad bracket the highest signable uptime: good signed (signedMsg
+	// holds its signature), bad did not. hasBad is false while no upper
+	// bound is known.
+	var good, bad uint64
+	var hasBad bool
+	var signedMsg *warp.Message
+
+	for idx, sample := range samples {
+		if ctx.Err() != nil {
+			return 0, nil
+		}
+		if hasBad && sample >= bad {
+			continue // duplicate of a sample that already failed
+		}
+		if attemptsLeft <= 0 {
+			break
+		}
+		logging.Infof("trying sample #%d with uptime = %d for %s", idx+1, sample, validationID)
+
+		signed, err := sign(sample)
+		if err != nil {
+			if !errors.Is(err, aggregator.ErrSigningFailed) {
+				logging.Errorf("cannot sign for %s: %v", validationID, err)
+				return 0, nil
+			}
+			logging.Infof("signing failed at sample %d (%d seconds) for %s: %v", idx+1, sample, validationID, err)
+			bad, hasBad = sample, true
+			continue
+		}
+
+		good, signedMsg = sample, signed
+		logging.Infof("initial signature succeeded with uptime = %d for %s", sample, validationID)
+		break
+	}
+
+	if signedMsg == nil && ctx.Err() == nil {
+		if !hasBad {
+			if len(samples) == 0 {
+				logging.Infof("no uptime samples to sign for %s", validationID)
+			} else {
+				logging.Infof("search attempt cap reached for %s before any sample was tried", validationID)
+			}
+			return 0, nil
+		}
+
+		// All samples tried failed, so search down from the lowest one. The
+		// floor is 0, which has no signature yet: a validator with nothing
+		// stored still gets the highest value below the samples that signs.
+		// A stored uptime raises the floor, since anything below it is
+		// already covered by the stored proof.
+		if storedUptime == 0 {
+			logging.Infof("all samples failed for %s and nothing is stored, searching below %d", validationID, bad)
+		} else if attemptsLeft > 0 {
+			logging.Infof("all samples failed for %s, trying stored uptime %d", validationID, storedUptime)
+			signed, err := sign(storedUptime)
+			if err != nil {
+				if !errors.Is(err, aggregator.ErrSigningFailed) {
+					logging.Errorf("cannot sign for %s: %v", validationID, err)
+					return 0, nil
+				}
+				logging.Infof("stored uptime signing failed for %s: %v", validationID, err)
+				return 0, nil
+			}
+			if storedUptime >= bad {
+				// Nothing between the stored value and the failed samples to search.
+				return storedUptime, signed
+			}
+			good, signedMsg = storedUptime, signed
+		}
+	}
+
+	// No failing value above good yet: probe upward in doubling steps,
+	// starting at 5% of good, until one fails.
+	step := max(good/20, resolution)
+	for !hasBad && attemptsLeft > 0 && ctx.Err() == nil {
+		next := good + step
+		logging.Infof("trying increased uptime = %d for %s", next, validationID)
+
+		signed, err := sign(next)
+		if err != nil {
+			if !errors.Is(err, aggregator.ErrSigningFailed) {
+				logging.Errorf("uptime search for %s abandoned at %d: %v", validationID, good, err)
+				return 0, nil
+			}
+			logging.Infof("failed at increased uptime = %d for %s", next, validationID)
+			bad, hasBad = next, true
+			break
+		}
+		good, signedMsg = next, signed
+		step *= 2
+	}
+
+	// Bisect (good, bad) down to the resolution.
+	for hasBad && bad-good > resolution && attemptsLeft > 0 && ctx.Err() == nil {
+		mid := good + (bad-good)/2
+		logging.Infof("trying uptime = %d for %s (signable >= %d, unsignable >= %d)", mid, validationID, good, bad)
+
+		signed, err := sign(mid)
+		if err != nil {
+			if !errors.Is(err, aggregator.ErrSigningFailed) {
+				logging.Errorf("uptime search for %s abandoned at %d: %v", validationID, good, err)
+				return 0, nil
+			}
+			bad = mid
+			continue
+		}
+		good, signedMsg = mid, signed
+	}
+
+	if ctx.Err() != nil {
+		logging.Infof("uptime search for %s interrupted: %v", validationID, ctx.Err())
+		return 0, nil
+	}
+	if signedMsg == nil {
+		if attemptsLeft <= 0 {
+			logging.Infof("search attempt cap reached for %s before any uptime signed", validationID)
+		} else {
+			logging.Infof("no uptime below %d could be signed for %s", bad, validationID)
+		}
+		return 0, nil
+	}
+	if attemptsLeft <= 0 {
+		logging.Infof("search attempt cap reached for %s, keeping %d", validationID, good)
+	}
+	logging.Infof("highest signable uptime for %s is %d", validationID, good)
+	return good, signedMsg
+}
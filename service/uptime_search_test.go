@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"uptime-service/aggregator"
+
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+)
+
+// fakeSigner signs any uptime up to limit and declines the rest, recording
+// every request.
+type fakeSigner struct {
+	limit uint64
+	calls []uint64
+}
+
+func (f *fakeSigner) sign(uptime uint64) (*warp.Message, error) {
+	f.calls = append(f.calls, uptime)
+	if uptime > f.limit {
+		return nil, fmt.Errorf("%w: validators declined %d", aggregator.ErrSigningFailed, uptime)
+	}
+	return &warp.Message{}, nil
+}
+
+func TestSearchSignableUptime(t *testing.T) {
+	tests := []struct {
+		name        string
+		samples     []uint64
+		stored      uint64
+		limit       uint64 // highest uptime the fake signs
+		resolution  uint64
+		maxAttempts int
+		want        uint64 // 0: nothing signed
+		wantCalls   int    // 0: not checked
+	}{
+		{
+			name:        "all samples fail, nothing stored",
+			samples:     []uint64{1000, 900, 800},
+			limit:       500,
+			resolution:  1,
+			maxAttempts: 20,
+			want:        500,
+		},
+		{
+			name:        "all samples fail, stored uptime raises the floor",
+			samples:     []uint64{1000},
+			stored:      550,
+			limit:       600,
+			resolution:  1,
+			maxAttempts: 20,
+			want:        600,
+		},
+		{
+			name:        "best sample signs and the probe goes up",
+			samples:     []uint64{1000, 900},
+			limit:       1234,
+			resolution:  1,
+			maxAttempts: 50,
+			want:        1234,
+		},
+		{
+			name:        "stops at the resolution",
+			samples:     []uint64{1000},
+			limit:       1234,
+			resolution:  100,
+			maxAttempts: 50,
+			// 1000 and 1100 sign, 1300 fails, 1200 signs and leaves the
+			// bounds 100 apart.
+			want:      1200,
+			wantCalls: 4,
+		},
+		{
+			name:        "attempt cap reached while probing",
+			samples:     []uint64{1000},
+			limit:       5000,
+			resolution:  1,
+			maxAttempts: 3,
+			want:        1150, // 1000, 1050, 1150
+			wantCalls:   3,
+		},
+		{
+			name:        "attempt cap reached on the samples",
+			samples:     []uint64{1000, 900, 800, 700},
+			limit:       750,
+			resolution:  1,
+			maxAttempts: 2,
+			want:        0,
+			wantCalls:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := &fakeSigner{limit: tt.limit}
+			got, msg := searchSignableUptime(context.Background(), "val", tt.samples, tt.stored, uptimeSearchLimits{
+				resolution:  tt.resolution,
+				maxAttempts: tt.maxAttempts,
+			}, signer.sign)
+
+			if got != tt.want {
+				t.Errorf("uptime = %d, want %d (requests %v)", got, tt.want, signer.calls)
+			}
+			if (msg != nil) != (tt.want != 0) {
+				t.Errorf("signed message = %v for uptime %d", msg, got)
+			}
+			if len(signer.calls) > tt.maxAttempts {
+				t.Errorf("made %d requests, over the cap of %d: %v", len(signer.calls), tt.maxAttempts, signer.calls)
+			}
+			if tt.wantCalls != 0 && len(signer.calls) != tt.wantCalls {
+				t.Errorf("made %d requests, want %d: %v", len(signer.calls), tt.wantCalls, signer.calls)
+			}
+		})
+	}
+}
+
+func TestSearchSignableUptimeAbandonsOnAggregatorOutage(t *testing.T) {
+	var calls int
+	trySign := func(uptime uint64) (*warp.Message, error) {
+		calls++
+		if calls == 1 {
+			return &warp.Message{}, nil
+		}
+		return nil, fmt.Errorf("%w: connection refused", aggregator.ErrAggregatorUnavailable)
+	}
+
+	got, msg := searchSignableUptime(context.Background(), "val", []uint64{1000}, 0, uptimeSearchLimits{
+		resolution:  1,
+		maxAttempts: 20,
+	}, trySign)
+	if got != 0 || msg != nil {
+		t.Errorf("got uptime %d after an outage, want the search abandoned", got)
+	}
+	if calls != 2 {
+		t.Errorf("made %d requests, want 2", calls)
+	}
+}
@@ -1,33 +1,37 @@
 package service
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"database/sql"
+	"errors"
 	"fmt"
-	"math"
-	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"uptime-service/aggregator"
+	"uptime-service/chain"
 	"uptime-service/config"
 	"uptime-service/contract"
 	"uptime-service/db"
 	"uptime-service/delegation"
+	"uptime-service/epoch"
 	"uptime-service/logging"
 	"uptime-service/notifier"
+	"uptime-service/subgraph"
 	"uptime-service/validator"
 
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+	"github.com/ava-labs/libevm/common"
+	"github.com/ava-labs/libevm/core/types"
 )
 
-const refreshPrefix = "refresh_required:"
-
 type UptimeService struct {
 	cfg           *config.Config
-	store         *db.UptimeStore
+	store         db.Store
 	aggClient     *aggregator.Client
 	contractCli   *contract.ContractClient
 	delegationCli *delegation.Client
@@ -38,8 +42,22 @@ func normalizeHex(hexStr string) string {
 	return strings.TrimPrefix(strings.ToLower(hexStr), "0x")
 }
 
+// newSender builds the transaction sender for the service key. Clients that
+// send from that key within one command must share it so their nonces don't
+// collide.
+func newSender(cfg *config.Config) (*chain.Sender, error) {
+	return chain.NewSender(cfg.BeamRPC, cfg.PrivateKey, chain.SenderConfig{
+		MaxFeeCap:             chain.GweiToWei(cfg.TxMaxFeeGwei),
+		MaxTipCap:             chain.GweiToWei(cfg.TxMaxTipGwei),
+		GasLimitMarginPercent: cfg.TxGasLimitMarginPercent,
+		ReceiptTimeout:        time.Duration(cfg.TxReceiptTimeoutSeconds) * time.Second,
+		ReplaceAfter:          time.Duration(cfg.TxReplaceAfterSeconds) * time.Second,
+		GasBumpPercent:        cfg.TxGasBumpPercent,
+	})
+}
+
 // NewUptimeService wires all dependencies together using your existing clients.
-func NewUptimeService(cfg *config.Config, store *db.UptimeStore) (*UptimeService, error) {
+func NewUptimeService(cfg *config.Config, store db.Store) (*UptimeService, error) {
 	agg, err := aggregator.NewClient(
 		cfg.AggregatorURL,
 		uint32(cfg.NetworkID),
@@ -52,24 +70,28 @@ func NewUptimeService(cfg *config.Config, store *db.UptimeStore) (*UptimeService
 		return nil, fmt.Errorf("init aggregator: %w", err)
 	}
 
-	contractCli, err := contract.NewContractClient(
-		cfg.BeamRPC,
-		cfg.StakingManagerAddress,
-		cfg.WarpMessengerAddress,
-		cfg.PrivateKey,
-	)
+	sender, err := newSender(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("init contract client: %w", err)
+		return nil, fmt.Errorf("init transaction sender: %w", err)
 	}
 
-	delegationCli, err := delegation.NewClient(
+	contractCli := contract.NewContractClient(
+		sender,
+		cfg.StakingManagerAddress,
+		cfg.DryRun,
+	)
+	delegationCli := delegation.NewClient(
 		cfg.GraphQLEndpoint,
-		cfg.BeamRPC,
 		cfg.StakingManagerAddress,
-		cfg.PrivateKey,
+		sender,
+		cfg.ResolveRewardsBatchGasSharePercent,
+		cfg.DryRun,
 	)
-	if err != nil {
-		return nil, fmt.Errorf("init delegation client: %w", err)
+
+	slackWebhook := cfg.SlackWebhookURL
+	if cfg.DryRun {
+		// Dry runs are ad-hoc checks; keep them out of the team channel.
+		slackWebhook = ""
 	}
 
 	return &UptimeService{
@@ -78,14 +100,72 @@ func NewUptimeService(cfg *config.Config, store *db.UptimeStore) (*UptimeService
 		aggClient:     agg,
 		contractCli:   contractCli,
 		delegationCli: delegationCli,
-		slack:         notifier.NewSlack(cfg.SlackWebhookURL),
+		slack:         notifier.NewSlack(slackWebhook),
 	}, nil
 }
 
-// computeSignedUptime encapsulates the “try highest sample, then ramp up by 5%, then
-// fall back to decreasing/DB-stored value” logic and is reused by both generate-only
-// and generate-and-submit flows.
+// EpochSchedule returns the staking epoch schedule read from the staking
+// manager contract. epoch_start_timestamp and epoch_duration_seconds in the
+// config, when both are set, must agree with it; they are only used on their
+// own, with a warning, when the contract can't be read.
+func (s *UptimeService) EpochSchedule(ctx context.Context) (epoch.Schedule, error) {
+	configured := s.cfg.EpochStartTimestamp > 0 && s.cfg.EpochDurationSeconds > 0
+
+	sched, err := s.contractCli.EpochSchedule(ctx)
+	if err != nil {
+		if !configured || ctx.Err() != nil {
+			return epoch.Schedule{}, fmt.Errorf("read epoch schedule from staking manager: %w", err)
+		}
+		logging.Errorf("read epoch schedule from staking manager: %v; using the configured schedule unchecked", err)
+		return epoch.NewSchedule(s.cfg.EpochStartTimestamp, s.cfg.EpochDurationSeconds)
+	}
+	if !configured {
+		return sched, nil
+	}
+
+	want, err := epoch.NewSchedule(s.cfg.EpochStartTimestamp, s.cfg.EpochDurationSeconds)
+	if err != nil {
+		return epoch.Schedule{}, err
+	}
+	if !want.Start.Equal(sched.Start) || want.Duration != sched.Duration {
+		return epoch.Schedule{}, fmt.Errorf(
+			"configured epoch schedule (start %d, duration %ds) does not match the staking manager's (start %d, duration %ds); fix or remove epoch_start_timestamp/epoch_duration_seconds",
+			s.cfg.EpochStartTimestamp,
+			s.cfg.EpochDurationSeconds,
+			sched.Start.Unix(),
+			int64(sched.Duration/time.Second),
+		)
+	}
+	return sched, nil
+}
+
+// LastCompletedEpoch returns the most recent epoch that has already ended,
+// the default epoch to resolve rewards for.
+func (s *UptimeService) LastCompletedEpoch(ctx context.Context) (uint64, error) {
+	current, err := s.CurrentEpoch(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if current == 0 {
+		return 0, fmt.Errorf("no staking epoch has completed yet")
+	}
+	return current - 1, nil
+}
+
+// CurrentEpoch returns the staking epoch in progress right now.
+func (s *UptimeService) CurrentEpoch(ctx context.Context) (uint64, error) {
+	sched, err := s.EpochSchedule(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return sched.At(time.Now()), nil
+}
+
+// computeSignedUptime finds the highest uptime the validator set will sign
+// for validationID, and is reused by both generate-only and
+// generate-and-submit flows. See searchSignableUptime for the search.
 func (s *UptimeService) computeSignedUptime(
+	ctx context.Context,
 	validationID string,
 	uptimeSamples []uint64,
 	storedProofs map[string]db.UptimeProof,
@@ -97,101 +177,36 @@ func (s *UptimeService) computeSignedUptime(
 		if err != nil {
 			return nil, err
 		}
-		return s.aggClient.SubmitAggregateRequest(unsignedMsg)
+		return s.aggClient.SubmitAggregateRequest(ctx, unsignedMsg)
 	}
 
-	var attempted bool
-
-	for idx, sample := range uptimeSamples {
-		logging.Infof("trying sample #%d with uptime = %d for %s", idx+1, sample, validationID)
-
-		signed, err := trySign(sample)
-		if err != nil {
-			logging.Infof("signing failed at sample %d (%d seconds) for %s: %v", idx+1, sample, validationID, err)
-			continue
-		}
-
-		attempted = true
-		signedMsg = signed
-		finalUptime = sample
-		logging.Infof("initial signature succeeded with uptime = %d for %s", sample, validationID)
-
-		if idx == 0 {
-			current := sample
-			for {
-				next := uint64(math.Ceil(float64(current) * 1.05))
-				if next <= current {
-					next = current + 1
-				}
-				logging.Infof("trying increased uptime = %d for %s", next, validationID)
-
-				signedNext, err := trySign(next)
-				if err != nil {
-					logging.Infof("failed at increased uptime = %d for %s, keeping %d", next, validationID, current)
-					break
-				}
-				current = next
-				finalUptime = current
-				signedMsg = signedNext
-			}
-		}
-		break
+	resolution := uint64(1)
+	if s.cfg.UptimeSearchResolutionSeconds > 1 {
+		resolution = uint64(s.cfg.UptimeSearchResolutionSeconds)
 	}
-
-	if attempted {
-		return finalUptime, signedMsg
-	}
-
-	// All initial samples failed – decrease from the lowest sample and/or stored DB uptime.
-	current := uptimeSamples[len(uptimeSamples)-1]
 	var storedUptime uint64
 	if proof, exists := storedProofs[validationID]; exists {
 		storedUptime = proof.UptimeSeconds
 	}
 
-	logging.Infof(
-		"all samples failed for %s. decreasing from %d by 5%% until <= stored (%d)",
-		validationID,
-		current,
-		storedUptime,
-	)
-
-	for {
-		current = uint64(math.Floor(float64(current) * 0.95))
-		if current == 0 {
-			logging.Infof("uptime reached 0 for %s, aborting", validationID)
-			break
-		}
-		if storedUptime > 0 && current <= storedUptime {
-			logging.Infof("trying stored uptime %d for %s", storedUptime, validationID)
-			signed, err := trySign(storedUptime)
-			if err == nil {
-				return storedUptime, signed
-			}
-			logging.Infof("stored uptime signing failed for %s: %v", validationID, err)
-			break
-		}
-
-		logging.Infof("trying decreased uptime = %d for %s", current, validationID)
-		signed, err := trySign(current)
-		if err == nil {
-			return current, signed
-		}
-	}
-
-	return 0, nil
+	return searchSignableUptime(ctx, validationID, uptimeSamples, storedUptime, uptimeSearchLimits{
+		resolution:  resolution,
+		maxAttempts: s.cfg.UptimeSearchMaxAttempts,
+	}, trySign)
 }
 
 func (s *UptimeService) storeUptimeProofWithRefresh(
+	ctx context.Context,
 	validationID ids.ID,
 	uptimeSeconds uint64,
 	signedMsg *warp.Message,
 ) error {
-	err := s.store.StoreUptimeProof(validationID, uptimeSeconds, signedMsg)
-	ok, stored := parseRefreshRequired(err)
-	if !ok {
+	err := s.store.StoreUptimeProof(ctx, validationID, uptimeSeconds, signedMsg)
+	var refresh *db.RefreshRequiredError
+	if !errors.As(err, &refresh) {
 		return err
 	}
+	stored := refresh.StoredUptime
 
 	logging.Infof("re-signing with stored higher uptime %d for %s", stored, validationID.String())
 	unsigned, packErr := s.aggClient.PackValidationUptimeMessage(
@@ -202,54 +217,55 @@ func (s *UptimeService) storeUptimeProofWithRefresh(
 	if packErr != nil {
 		return fmt.Errorf("repack for refresh: %w", packErr)
 	}
-	signed, signErr := s.aggClient.SubmitAggregateRequest(unsigned)
+	signed, signErr := s.aggClient.SubmitAggregateRequest(ctx, unsigned)
 	if signErr != nil {
 		return fmt.Errorf("refresh signature failed: %w", signErr)
 	}
-	if storeErr := s.store.StoreUptimeProof(validationID, stored, signed); storeErr != nil {
+	if storeErr := s.store.StoreUptimeProof(ctx, validationID, stored, signed); storeErr != nil {
 		return fmt.Errorf("refresh store failed: %w", storeErr)
 	}
 	logging.Infof("refreshed record for %s at stored uptime %d", validationID.String(), stored)
 	return nil
 }
 
-// parseRefreshRequired checks if an error is of the form "refresh_required:<N>".
-func parseRefreshRequired(err error) (bool, uint64) {
-	if err == nil {
-		return false, 0
-	}
-	msg := err.Error()
-	if !strings.HasPrefix(msg, refreshPrefix) {
-		return false, 0
-	}
-	var v uint64
-	_, scanErr := fmt.Sscanf(msg, refreshPrefix+"%d", &v)
-	if scanErr != nil {
-		return false, 0
-	}
-	return true, v
-}
-
 // runOutcome tracks per-validator results across a single
 // generate-and-submit run, so we can surface a summary at the end.
 type runOutcome struct {
-	submitted        []string // proof landed on-chain (regardless of DB store result)
+	submitted        []string // proof mined successfully on-chain (regardless of DB store result)
 	failedSign       []string // no sample/fallback could be signed by quorum
-	failedSubmit     []string // signed but the staking-manager tx reverted/failed
+	failedSubmit     []string // signed but the staking-manager tx could not be sent
+	reverted         []string // tx mined but reverted
+	unconfirmed      []string // tx sent but no receipt within the timeout
+	upToDate         []string // on-chain uptime already at or above what we could prove
 	failedStore      []string // submitted on-chain but local DB store failed
 	noSamples        []string // node fleet returned zero uptime samples (often: deactivated)
 	bootstrapSkipped int
 	parseSkipped     int
+	interrupted      int // not submitted because the run was cancelled
+	nodes            []validator.NodeReport
+	epoch            sql.NullInt64     // epoch the run submits in, for the proof history
+	errs             map[string]string // why a validator failed, for the run record
 }
 
-// GenerateAndSubmitUptimeProofs is the end-to-end path: fetch -> sign -> submit -> store.
-func (s *UptimeService) GenerateAndSubmitUptimeProofs(ctx context.Context) error {
-	_ = ctx
+// setErr remembers why validationID failed.
+func (o *runOutcome) setErr(validationID string, err error) {
+	if o.errs == nil {
+		o.errs = make(map[string]string)
+	}
+	o.errs[validationID] = err.Error()
+}
 
+// GenerateAndSubmitUptimeProofs is the end-to-end path: fetch -> sign -> submit -> store.
+// Cancelling ctx stops handing out work and skips submissions that have not
+// started; the summary for whatever completed is still logged and posted.
+func (s *UptimeService) GenerateAndSubmitUptimeProofs(ctx context.Context) (err error) {
 	runStart := time.Now()
 	logging.Info("starting end-to-end uptime proof generation and submission")
 
-	if err := s.slack.Post(s.formatStartMessage(runStart)); err != nil {
+	run := startRun(ctx, s.store, s.cfg, "generate-and-submit")
+	defer func() { run.finish(ctx, err) }()
+
+	if err := s.slack.Post(ctx, s.formatStartMessage(runStart)); err != nil {
 		logging.Errorf("slack start notification failed: %v", err)
 	}
 
@@ -258,81 +274,468 @@ func (s *UptimeService) GenerateAndSubmitUptimeProofs(ctx context.Context) error
 		bootstrapMap[id] = true
 	}
 
-	uptimeMap := validator.FetchAggregatedUptimes(s.cfg.AvalancheAPIList)
+	fetch := validator.FetchAggregatedUptimes(ctx, s.cfg.AvalancheAPIList)
+	uptimeMap := fetch.Uptimes
+	logNodeReports(fetch)
 	logging.Infof(
-		"fetched uptime info for %d validationIDs from %d nodes",
+		"fetched uptime info for %d validationIDs from %d/%d nodes",
 		len(uptimeMap),
-		len(s.cfg.AvalancheAPIList),
+		fetch.Responded(),
+		len(fetch.Nodes),
 	)
 
-	storedProofs, err := s.store.GetAllUptimeProofs()
+	storedProofs, err := s.store.GetAllUptimeProofs(ctx)
 	if err != nil {
 		return fmt.Errorf("load stored proofs: %w", err)
 	}
 
-	var outcome runOutcome
+	outcome := runOutcome{nodes: fetch.Nodes, epoch: s.runEpoch(ctx)}
 
-	for validationID, uptimeSamples := range uptimeMap {
+	pending := make([]string, 0, len(uptimeMap))
+	for validationID := range uptimeMap {
 		if bootstrapMap[validationID] {
 			logging.Infof("⏩ skipping bootstrap validator %s", validationID)
 			outcome.bootstrapSkipped++
 			continue
 		}
+		pending = append(pending, validationID)
+	}
 
-		start := time.Now()
-		logging.Infof("==== processing validator %s ====", validationID)
+	workers := s.cfg.SigningWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	logging.Infof("signing %d validators with %d workers", len(pending), workers)
+
+	// Signing fans out over the worker pool; everything after it — the
+	// on-chain submission, the DB store and the outcome accounting — stays
+	// on this goroutine so transactions from the service key go out one at
+	// a time in nonce order.
+	jobs := make(chan string)
+	results := make(chan signedUptime)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for validationID := range jobs {
+				results <- s.signValidator(ctx, validationID, uptimeMap[validationID], storedProofs)
+			}
+		}()
+	}
+	go func() {
+		defer close(results)
+		defer wg.Wait()
+		defer close(jobs)
+		for _, validationID := range pending {
+			select {
+			case jobs <- validationID:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
-		if len(uptimeSamples) == 0 {
-			logging.Infof("no uptime samples for %s", validationID)
-			outcome.noSamples = append(outcome.noSamples, validationID)
+	for signed := range results {
+		if ctx.Err() != nil {
+			outcome.interrupted++
 			continue
 		}
+		s.submitSignedUptime(ctx, signed, &outcome)
+	}
+	if ctx.Err() != nil {
+		outcome.interrupted += len(pending) - outcome.handled()
+	}
+
+	// The summary goes out even when the run was cancelled: that is exactly
+	// when the team needs to know how far it got.
+	run.addOutcome(outcome)
+	summary := s.formatSummaryMessage(outcome, time.Since(runStart))
+	logging.Infof("run summary:\n%s", summary)
+	if err := s.slack.Post(context.WithoutCancel(ctx), summary); err != nil {
+		logging.Errorf("slack completion notification failed: %v", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("run interrupted: %w", err)
+	}
+	return nil
+}
+
+// handled counts the non-bootstrap validators that reached a final bucket.
+func (o runOutcome) handled() int {
+	return len(o.submitted) + len(o.failedSign) + len(o.failedSubmit) +
+		len(o.reverted) + len(o.unconfirmed) + len(o.upToDate) + len(o.noSamples) + o.parseSkipped + o.interrupted
+}
+
+// degradedNodeScore is the health score below which a node that did answer
+// is called out as lagging: it reported noticeably fewer validators than the
+// best node, so its samples drag the aggregate down.
+const degradedNodeScore = 0.9
+
+// logNodeReports logs how each node fared in a fetch, so a thin node fleet
+// explains itself instead of showing up only as lower uptimes.
+func logNodeReports(fetch *validator.FetchResult) {
+	for _, node := range fetch.Nodes {
+		switch {
+		case node.Err != nil:
+			logging.Errorf("node %s failed after %s: %v", node.Endpoint, node.Latency.Round(time.Millisecond), node.Err)
+		case node.HealthScore < degradedNodeScore:
+			logging.Infof(
+				"node %s is lagging: %d validators in %s (health %.2f)",
+				node.Endpoint, node.ValidatorCount, node.Latency.Round(time.Millisecond), node.HealthScore,
+			)
+		default:
+			logging.Infof("node %s reported %d validators in %s", node.Endpoint, node.ValidatorCount, node.Latency.Round(time.Millisecond))
+		}
+	}
+}
+
+// signedUptime is the signing phase's result for one validator. signedMsg is
+// nil when there is nothing to submit; failure says which outcome bucket the
+// validator belongs in.
+type signedUptime struct {
+	validationID string
+	valID        ids.ID
+	uptime       uint64
+	signedMsg    *warp.Message
+	signedAt     time.Time
+	failure      signFailure
+	started      time.Time
+}
 
-		finalUptime, signedMsg := s.computeSignedUptime(
+type signFailure int
+
+const (
+	signOK signFailure = iota
+	signNoSamples
+	signBadID
+	signNoQuorum
+	signUpToDate
+)
+
+// signValidator finds and signs the highest provable uptime for one
+// validator. It only talks to the aggregator, so it is safe to run
+// concurrently across validators.
+func (s *UptimeService) signValidator(
+	ctx context.Context,
+	validationID string,
+	uptimeSamples []uint64,
+	storedProofs map[string]db.UptimeProof,
+) signedUptime {
+	result := signedUptime{validationID: validationID, started: time.Now()}
+	logging.Infof("==== processing validator %s ====", validationID)
+
+	if len(uptimeSamples) == 0 {
+		logging.Infof("no uptime samples for %s", validationID)
+		result.failure = signNoSamples
+		return result
+	}
+
+	valID, err := ids.FromString(validationID)
+	if err != nil {
+		logging.Errorf("invalid validator ID format for %s: %v", validationID, err)
+		result.failure = signBadID
+		return result
+	}
+	result.valID = valID
+
+	// Samples are sorted descending, so if even the best one doesn't beat
+	// the chain there is nothing to sign for.
+	onChain, onChainKnown := s.onChainUptime(ctx, valID)
+	if onChainKnown && uptimeSamples[0] <= onChain {
+		logging.Infof(
+			"⏩ %s is already up to date on-chain (%d seconds, best sample %d)",
 			validationID,
-			uptimeSamples,
-			storedProofs,
+			onChain,
+			uptimeSamples[0],
 		)
-		if signedMsg == nil {
-			logging.Errorf("❌ could not get any valid signature for %s", validationID)
-			outcome.failedSign = append(outcome.failedSign, validationID)
-			continue
-		}
+		result.failure = signUpToDate
+		return result
+	}
 
-		valID, err := ids.FromString(validationID)
-		if err != nil {
-			logging.Errorf("invalid validator ID format for %s: %v", validationID, err)
-			outcome.parseSkipped++
-			continue
-		}
+	result.uptime, result.signedMsg = s.computeSignedUptime(
+		ctx,
+		validationID,
+		uptimeSamples,
+		storedProofs,
+	)
+	if result.signedMsg == nil {
+		logging.Errorf("❌ could not get any valid signature for %s", validationID)
+		result.failure = signNoQuorum
+		return result
+	}
+	result.signedAt = time.Now()
 
-		if err := s.contractCli.SubmitUptimeProof(valID, signedMsg); err != nil {
+	if onChainKnown && result.uptime <= onChain {
+		logging.Infof(
+			"⏩ %s is already up to date on-chain (%d seconds, signed %d)",
+			validationID,
+			onChain,
+			result.uptime,
+		)
+		result.failure = signUpToDate
+	}
+	return result
+}
+
+// onChainUptime reads the uptime the staking manager already holds for a
+// validator. A failed read is logged and reported as unknown, in which case
+// the proof is submitted anyway: a wasted transaction beats a missed one.
+func (s *UptimeService) onChainUptime(ctx context.Context, valID ids.ID) (uint64, bool) {
+	uptime, err := s.contractCli.GetValidatorUptime(ctx, valID)
+	if err != nil {
+		logging.Errorf("read on-chain uptime for %s, submitting without the check: %v", valID, err)
+		return 0, false
+	}
+	return uptime, true
+}
+
+// submitSignedUptime runs submit -> store for a signing result and records
+// the outcome. It must only be called from one goroutine at a time.
+func (s *UptimeService) submitSignedUptime(ctx context.Context, signed signedUptime, outcome *runOutcome) {
+	validationID := signed.validationID
+
+	switch signed.failure {
+	case signNoSamples:
+		outcome.noSamples = append(outcome.noSamples, validationID)
+		return
+	case signBadID:
+		outcome.parseSkipped++
+		return
+	case signNoQuorum:
+		outcome.failedSign = append(outcome.failedSign, validationID)
+		return
+	case signUpToDate:
+		outcome.upToDate = append(outcome.upToDate, validationID)
+		return
+	}
+
+	sub, err := s.contractCli.SubmitUptimeProof(ctx, signed.valID, signed.signedMsg)
+	recordSubmission(ctx, s.store, proofAttempt{
+		valID:     signed.valID,
+		epoch:     outcome.epoch,
+		uptime:    signed.uptime,
+		signedMsg: signed.signedMsg,
+		signedAt:  signed.signedAt,
+	}, sub, err)
+	if err != nil {
+		switch {
+		case errors.Is(err, chain.ErrReverted):
+			logging.Errorf("❌ uptime proof for %s reverted: %v", validationID, err)
+			outcome.reverted = append(outcome.reverted, validationID)
+		case errors.Is(err, chain.ErrReceiptTimeout):
+			logging.Errorf("❌ uptime proof for %s was sent but not confirmed: %v", validationID, err)
+			outcome.unconfirmed = append(outcome.unconfirmed, validationID)
+		default:
 			logging.Errorf("❌ contract submission failed for %s: %v", validationID, err)
 			outcome.failedSubmit = append(outcome.failedSubmit, validationID)
-			continue
 		}
+		outcome.setErr(validationID, err)
+		return
+	}
 
-		// Proof is on-chain at this point — count it as submitted regardless
-		// of whether the local DB write succeeds.
-		outcome.submitted = append(outcome.submitted, validationID)
+	// Proof is mined at this point — count it as submitted regardless
+	// of whether the local DB write succeeds.
+	outcome.submitted = append(outcome.submitted, validationID)
 
-		if err := s.storeUptimeProofWithRefresh(valID, finalUptime, signedMsg); err != nil {
-			logging.Errorf("❌ failed to store uptime proof for %s: %v", validationID, err)
-			outcome.failedStore = append(outcome.failedStore, validationID)
-			continue
+	if err := s.storeUptimeProofWithRefresh(ctx, signed.valID, signed.uptime, signed.signedMsg); err != nil {
+		logging.Errorf("❌ failed to store uptime proof for %s: %v", validationID, err)
+		outcome.failedStore = append(outcome.failedStore, validationID)
+		outcome.setErr(validationID, err)
+		return
+	}
+
+	logging.Infof("✅ stored and submitted uptime proof for %s at %d seconds", validationID, signed.uptime)
+	logging.Infof("finished processing %s in %s", validationID, time.Since(signed.started))
+}
+
+// runEpoch returns the current epoch for labelling the run's proof history,
+// or NULL if the schedule can't be read; that must not stop the run.
+func (s *UptimeService) runEpoch(ctx context.Context) sql.NullInt64 {
+	current, err := s.CurrentEpoch(ctx)
+	if err != nil {
+		logging.Errorf("could not determine the current epoch, proof history will have no epoch: %v", err)
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(current), Valid: true}
+}
+
+// proofAttempt is a signed proof about to be submitted.
+type proofAttempt struct {
+	valID     ids.ID
+	epoch     sql.NullInt64
+	uptime    uint64
+	signedMsg *warp.Message
+	signedAt  time.Time
+}
+
+// recordSubmission appends the result of submitting p to the proof history
+// and, if a transaction was sent, to the transactions table. A failed write
+// is only logged: both are audit trails, not inputs to the run.
+func recordSubmission(
+	ctx context.Context,
+	store db.Store,
+	p proofAttempt,
+	sub contract.Submission,
+	submitErr error,
+) {
+	entry := db.ProofHistoryEntry{
+		ValidationID:  p.valID,
+		Epoch:         p.epoch,
+		UptimeSeconds: p.uptime,
+		SignedMessage: p.signedMsg,
+		Status:        db.ProofSubmitted,
+		SignedAt:      p.signedAt,
+	}
+	if sub.TxHash != (common.Hash{}) {
+		entry.TxHash = sub.TxHash.Hex()
+	}
+	if submitErr != nil {
+		entry.Error = submitErr.Error()
+		switch {
+		case errors.Is(submitErr, chain.ErrReverted):
+			entry.Status = db.ProofReverted
+		case errors.Is(submitErr, chain.ErrReceiptTimeout):
+			entry.Status = db.ProofUnconfirmed
+		default:
+			entry.Status = db.ProofFailed
 		}
+	}
 
-		logging.Infof("✅ stored and submitted uptime proof for %s at %d seconds", validationID, finalUptime)
-		logging.Infof("finished processing %s in %s", validationID, time.Since(start))
+	// Record even when the run is being cancelled: the transaction may
+	// already be out.
+	ctx = context.WithoutCancel(ctx)
+	if err := store.RecordProofHistory(ctx, entry); err != nil {
+		logging.Errorf("failed to record proof history for %s: %v", p.valID, err)
+	}
+	if sub.TxHash != (common.Hash{}) {
+		recordTransaction(ctx, store, txRecord(
+			db.TxUptimeProof,
+			p.valID,
+			p.epoch,
+			sub.TxHash,
+			sub.Nonce,
+			sub.Receipt,
+			submitErr,
+		))
 	}
+}
 
-	if err := s.slack.Post(s.formatSummaryMessage(outcome, time.Since(runStart))); err != nil {
-		logging.Errorf("slack completion notification failed: %v", err)
+// txRecord describes a sent transaction for the transactions table from its
+// receipt, if it was mined, and the error waiting for it returned.
+func txRecord(
+	kind db.TxKind,
+	valID ids.ID,
+	epoch sql.NullInt64,
+	hash common.Hash,
+	nonce uint64,
+	receipt *types.Receipt,
+	waitErr error,
+) db.TxRecord {
+	rec := db.TxRecord{
+		Kind:         kind,
+		TxHash:       hash.Hex(),
+		Nonce:        nonce,
+		ValidationID: valID,
+		Epoch:        epoch,
+		Status:       db.TxUnconfirmed,
+	}
+	if waitErr != nil {
+		rec.Error = waitErr.Error()
+	}
+	if receipt == nil {
+		return rec
+	}
+
+	rec.Status = db.TxMined
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		rec.Status = db.TxReverted
+	}
+	if receipt.BlockNumber != nil {
+		rec.BlockNumber = sql.NullInt64{Int64: receipt.BlockNumber.Int64(), Valid: true}
+	}
+	rec.GasUsed = sql.NullInt64{Int64: int64(receipt.GasUsed), Valid: true}
+	if receipt.EffectiveGasPrice != nil {
+		rec.EffectiveGasPrice = sql.NullString{String: receipt.EffectiveGasPrice.String(), Valid: true}
+	}
+	return rec
+}
+
+// recordTransaction writes rec, logging rather than returning a failure.
+func recordTransaction(ctx context.Context, store db.Store, rec db.TxRecord) {
+	if err := store.RecordTransaction(ctx, rec); err != nil {
+		logging.Errorf("failed to record %s transaction %s for %s: %v", rec.Kind, rec.TxHash, rec.ValidationID, err)
+	}
+}
+
+// GenerateAndSubmitForValidator runs fetch -> sign -> submit -> store for a
+// single validator, for on-call fixes that shouldn't touch the whole fleet.
+// It returns an error unless the proof landed on-chain and was stored.
+func (s *UptimeService) GenerateAndSubmitForValidator(ctx context.Context, validationID string) (err error) {
+	run := startRun(ctx, s.store, s.cfg, "submit-validator")
+	defer func() { run.finish(ctx, err) }()
+
+	if _, err := ids.FromString(validationID); err != nil {
+		return fmt.Errorf("invalid validation ID %s: %w", validationID, err)
+	}
+	for _, id := range s.cfg.BootstrapValidators {
+		if id == validationID {
+			return fmt.Errorf("%s is a bootstrap validator and is excluded from uptime proofs", validationID)
+		}
+	}
+
+	fetch := validator.FetchAggregatedUptimes(ctx, s.cfg.AvalancheAPIList)
+	logNodeReports(fetch)
+	uptimeSamples := fetch.Uptimes[validationID]
+	logging.Infof(
+		"fetched %d uptime samples for %s from %d/%d nodes",
+		len(uptimeSamples),
+		validationID,
+		fetch.Responded(),
+		len(fetch.Nodes),
+	)
+
+	storedProofs, err := s.store.GetAllUptimeProofs(ctx)
+	if err != nil {
+		return fmt.Errorf("load stored proofs: %w", err)
 	}
 
+	outcome := runOutcome{epoch: s.runEpoch(ctx)}
+	s.submitSignedUptime(ctx, s.signValidator(ctx, validationID, uptimeSamples, storedProofs), &outcome)
+	run.addOutcome(outcome)
+
+	switch {
+	case ctx.Err() != nil:
+		return fmt.Errorf("interrupted: %w", ctx.Err())
+	case len(outcome.upToDate) > 0:
+		logging.Infof("%s is already up to date on-chain, nothing submitted", validationID)
+		return nil
+	case len(outcome.noSamples) > 0:
+		return fmt.Errorf("no uptime samples for %s (likely deactivated)", validationID)
+	case len(outcome.failedSign) > 0:
+		return fmt.Errorf("could not get a quorum signature for %s", validationID)
+	case len(outcome.failedSubmit) > 0:
+		return fmt.Errorf("contract submission failed for %s", validationID)
+	case len(outcome.reverted) > 0:
+		return fmt.Errorf("uptime proof transaction for %s reverted", validationID)
+	case len(outcome.unconfirmed) > 0:
+		return fmt.Errorf("uptime proof transaction for %s was not confirmed in time", validationID)
+	case len(outcome.failedStore) > 0:
+		return fmt.Errorf("proof for %s is on-chain but storing it failed", validationID)
+	}
 	return nil
 }
 
+// dryRunPrefix marks log summaries of dry runs, which only describe what a
+// real run would have done.
+func (s *UptimeService) dryRunPrefix() string {
+	if s.cfg.DryRun {
+		return "DRY RUN: "
+	}
+	return ""
+}
+
 func (s *UptimeService) networkLabel() string {
 	switch s.cfg.NetworkID {
 	case 1:
@@ -355,18 +758,40 @@ func (s *UptimeService) formatStartMessage(runStart time.Time) string {
 func (s *UptimeService) formatSummaryMessage(o runOutcome, dur time.Duration) string {
 	var sb strings.Builder
 
-	hasFailures := len(o.failedSign) > 0 || len(o.failedSubmit) > 0 || len(o.failedStore) > 0
+	respondedNodes := 0
+	for _, node := range o.nodes {
+		if node.Err == nil {
+			respondedNodes++
+		}
+	}
+
+	hasFailures := len(o.failedSign) > 0 || len(o.failedSubmit) > 0 || len(o.failedStore) > 0 ||
+		len(o.reverted) > 0 || len(o.unconfirmed) > 0 || o.interrupted > 0 || respondedNodes < len(o.nodes)
 	if hasFailures {
 		sb.WriteString(":warning: ")
 	} else {
 		sb.WriteString(":white_check_mark: ")
 	}
-	fmt.Fprintf(&sb, "*Uptime proof run completed* — `%s` (took %s)\n",
-		s.networkLabel(), dur.Round(time.Second))
-
-	fmt.Fprintf(&sb, "• Submitted on-chain: *%d*\n", len(o.submitted))
+	if s.cfg.DryRun {
+		fmt.Fprintf(&sb, "*Uptime proof dry run completed* — `%s` (took %s)\n",
+			s.networkLabel(), dur.Round(time.Second))
+		fmt.Fprintf(&sb, "• Would submit on-chain (simulated): *%d*\n", len(o.submitted))
+	} else {
+		fmt.Fprintf(&sb, "*Uptime proof run completed* — `%s` (took %s)\n",
+			s.networkLabel(), dur.Round(time.Second))
+		fmt.Fprintf(&sb, "• Submitted on-chain: *%d*\n", len(o.submitted))
+	}
+	if len(o.nodes) > 0 {
+		fmt.Fprintf(&sb, "• Nodes responding: *%d/%d*\n", respondedNodes, len(o.nodes))
+	}
+	fmt.Fprintf(&sb, "• Already up to date on-chain: *%d*\n", len(o.upToDate))
 	fmt.Fprintf(&sb, "• Failed to sign (quorum): *%d*\n", len(o.failedSign))
-	fmt.Fprintf(&sb, "• Failed to submit (tx revert): *%d*\n", len(o.failedSubmit))
+	fmt.Fprintf(&sb, "• Failed to send tx: *%d*\n", len(o.failedSubmit))
+	fmt.Fprintf(&sb, "• Reverted on-chain: *%d*\n", len(o.reverted))
+	if len(o.unconfirmed) > 0 {
+		fmt.Fprintf(&sb, "• Sent but unconfirmed (no receipt in %ds): *%d*\n",
+			s.cfg.TxReceiptTimeoutSeconds, len(o.unconfirmed))
+	}
 	fmt.Fprintf(&sb, "• No uptime samples (likely deactivated): *%d*\n", len(o.noSamples))
 	if o.bootstrapSkipped > 0 {
 		fmt.Fprintf(&sb, "• Skipped bootstrap validators: %d\n", o.bootstrapSkipped)
@@ -377,15 +802,58 @@ func (s *UptimeService) formatSummaryMessage(o runOutcome, dur time.Duration) st
 	if o.parseSkipped > 0 {
 		fmt.Fprintf(&sb, "• Skipped malformed validation IDs: %d\n", o.parseSkipped)
 	}
+	if o.interrupted > 0 {
+		fmt.Fprintf(&sb, "• Not processed, run was interrupted: *%d*\n", o.interrupted)
+	}
 
 	appendIDs(&sb, "Signature failures", o.failedSign)
 	appendIDs(&sb, "Submission failures", o.failedSubmit)
+	appendIDs(&sb, "Reverted transactions", o.reverted)
+	appendIDs(&sb, "Unconfirmed transactions (check the explorer)", o.unconfirmed)
 	appendIDs(&sb, "DB store failures (already on-chain)", o.failedStore)
 	appendIDs(&sb, "No samples (likely deactivated)", o.noSamples)
+	appendNodeProblems(&sb, o.nodes)
 
 	return sb.String()
 }
 
+// appendNodeProblems lists the nodes that failed or lagged behind the rest of
+// the fleet. Nodes are shown by host only: endpoint URLs can carry API keys,
+// and Slack is not the place for them.
+func appendNodeProblems(sb *strings.Builder, nodes []validator.NodeReport) {
+	var lines []string
+	for _, node := range nodes {
+		host := nodeHost(node.Endpoint)
+		switch {
+		case node.Err != nil:
+			reason := strings.ReplaceAll(node.Err.Error(), node.Endpoint, host)
+			lines = append(lines, fmt.Sprintf("• `%s` — failed after %s: %s",
+				host, node.Latency.Round(time.Millisecond), reason))
+		case node.HealthScore < degradedNodeScore:
+			lines = append(lines, fmt.Sprintf("• `%s` — lagging: %d validators (health %.2f, %s)",
+				host, node.ValidatorCount, node.HealthScore, node.Latency.Round(time.Millisecond)))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	sb.WriteString("\n*Node problems:*\n")
+	for _, line := range lines {
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+}
+
+// nodeHost reduces an endpoint URL to its host, dropping any path or query
+// that may hold credentials.
+func nodeHost(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return "unparseable endpoint"
+	}
+	return u.Host
+}
+
 // appendIDs renders a labeled bullet list of validation IDs into sb,
 // capping at 20 entries to keep Slack messages readable.
 func appendIDs(sb *strings.Builder, label string, ids []string) {
@@ -406,11 +874,18 @@ func appendIDs(sb *strings.Builder, label string, ids []string) {
 	}
 }
 
-// Resolves delegations for all the validators.
-func (s *UptimeService) ResolveRewards(ctx context.Context) error {
-	_ = ctx
+// Resolves delegations for all the validators for the given epoch.
+func (s *UptimeService) ResolveRewards(ctx context.Context, epochNum uint64) (err error) {
+	run := startRun(ctx, s.store, s.cfg, fmt.Sprintf("resolve-rewards -epoch %d", epochNum))
+	defer func() { run.finish(ctx, err) }()
+
+	sched, err := s.EpochSchedule(ctx)
+	if err != nil {
+		return err
+	}
+	epochEnd := sched.EndOf(epochNum)
 
-	proofs, err := s.store.GetAllUptimeProofs()
+	proofs, err := s.store.GetAllUptimeProofs(ctx)
 	if err != nil {
 		return fmt.Errorf("load uptime proofs: %w", err)
 	}
@@ -424,169 +899,363 @@ func (s *UptimeService) ResolveRewards(ctx context.Context) error {
 		unique[validationID] = true
 	}
 
-	logging.Infof("resolving rewards for %d validators", len(unique))
+	logging.Infof(
+		"resolving rewards for %d validators for epoch %d (ended %s)",
+		len(unique),
+		epochNum,
+		epochEnd.Format(time.RFC3339),
+	)
 
+	var resolved, noDelegations, failed, delegationCount, skippedCount int
 	for validationID := range unique {
-		delegations, err := s.delegationCli.GetDelegationsForValidator(validationID)
-		if err != nil {
-			logging.Errorf("fetch delegations for %s: %v", validationID, err)
-			continue
-		}
-
-		if len(delegations) == 0 {
-			logging.Infof("no delegations for %s", validationID)
-			continue
+		if ctx.Err() != nil {
+			break
 		}
-
-		if err := s.delegationCli.ResolveRewards(delegations); err != nil {
-			logging.Errorf("resolve rewards for %s: %v", validationID, err)
-			continue
+		res, err := s.resolveValidatorRewards(ctx, validationID, epochNum, epochEnd)
+		delegationCount += res.Resolved
+		skippedCount += res.Skipped
+		switch {
+		case err != nil:
+			logging.Errorf("%v", err)
+			failed++
+			run.add(validationID, "failed", err)
+		case res.Resolved == 0 && res.Skipped == 0:
+			noDelegations++
+			run.add(validationID, "no_delegations", nil)
+		default:
+			resolved++
+			run.add(validationID, "resolved", nil)
 		}
-		logging.Infof("successfully resolved rewards for validator %s", validationID)
 	}
 
+	logging.Infof(
+		"%sresolve-rewards summary for epoch %d: %d validators resolved, %d without delegations, %d with failures; "+
+			"%d delegations resolved, %d skipped as already resolved or not eligible",
+		s.dryRunPrefix(),
+		epochNum,
+		resolved,
+		noDelegations,
+		failed,
+		delegationCount,
+		skippedCount,
+	)
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("resolve rewards interrupted: %w", err)
+	}
 	return nil
 }
 
-// SubmitMissingUptimeProofs checks the subgraph for missing uptime submissions
-// for a given epoch, and submits/re-signs proofs as needed.
-func SubmitMissingUptimeProofs(
-	_ context.Context,
-	cfg *config.Config,
-	store *db.UptimeStore,
-) error {
-	const epochID = "663"
-	logging.Infof("checking for missing uptime submissions in epoch %s", epochID)
+// ResolveValidatorRewards resolves rewards for the delegations of a single
+// validator for epochNum. The validator must have a proof in the DB.
+func (s *UptimeService) ResolveValidatorRewards(ctx context.Context, validationID string, epochNum uint64) (err error) {
+	run := startRun(ctx, s.store, s.cfg, fmt.Sprintf("resolve-validator -epoch %d", epochNum))
+	defer func() { run.finish(ctx, err) }()
 
-	proofs, err := store.GetAllUptimeProofs()
+	sched, err := s.EpochSchedule(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to fetch from DB: %w", err)
+		return err
 	}
 
-	hexToCB58 := map[string]string{}
-	hexToProof := map[string]db.UptimeProof{}
+	proofs, err := s.store.GetAllUptimeProofs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to query proofs: %w", err)
+	}
 
-	for cb58ID, proof := range proofs {
-		hexID := normalizeHex(proof.ValidationID.Hex())
-		hexToCB58[hexID] = cb58ID
-		hexToProof[hexID] = proof
+	proof, ok := proofs[validationID]
+	if !ok {
+		return fmt.Errorf("validation ID %s not found in DB", validationID)
 	}
 
-	query := `
-	query getUptimeUpdates {
-		uptimeUpdates(first: 1000, where: { epoch: "` + epochID + `" }) {
-			validationID
+	logging.Infof("found DB entry with uptime = %d for %s", proof.UptimeSeconds, validationID)
+
+	res, err := s.resolveValidatorRewards(ctx, validationID, epochNum, sched.EndOf(epochNum))
+	if err != nil {
+		run.add(validationID, "failed", err)
+		if res.Resolved > 0 {
+			logging.Infof("%sresolved %d delegations for %s in epoch %d before failing", s.dryRunPrefix(), res.Resolved, validationID, epochNum)
 		}
-	}`
-	reqBody, _ := json.Marshal(map[string]string{
-		"query": query,
-	})
-	resp, err := http.Post(cfg.GraphQLEndpoint, "application/json", bytes.NewBuffer(reqBody))
+		return err
+	}
+	run.add(validationID, "resolved", nil)
+	logging.Infof(
+		"%sresolved %d delegations for %s in epoch %d (%d skipped as already resolved or not eligible)",
+		s.dryRunPrefix(),
+		res.Resolved,
+		validationID,
+		epochNum,
+		res.Skipped,
+	)
+	return nil
+}
+
+// resolveValidatorRewards resolves the due delegations of one validator. The
+// result counts what was resolved and skipped, also when some delegations
+// failed.
+func (s *UptimeService) resolveValidatorRewards(
+	ctx context.Context,
+	validationID string,
+	epochNum uint64,
+	epochEnd time.Time,
+) (delegation.ResolveResult, error) {
+	delegations, err := s.delegationCli.GetDelegationsForValidator(ctx, validationID, epochNum, epochEnd)
 	if err != nil {
-		return fmt.Errorf("failed to send GraphQL request: %w", err)
+		return delegation.ResolveResult{}, fmt.Errorf("fetch delegations for %s: %w", validationID, err)
 	}
-	defer resp.Body.Close()
 
-	var gqlResp struct {
-		Data struct {
-			UptimeUpdates []struct {
-				ValidationID string `json:"validationID"`
-			} `json:"uptimeUpdates"`
-		} `json:"data"`
+	if len(delegations) == 0 {
+		logging.Infof("no delegations for %s", validationID)
+		return delegation.ResolveResult{}, nil
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&gqlResp); err != nil {
-		return fmt.Errorf("failed to decode GraphQL response: %w", err)
+
+	logging.Infof("found %d delegations for validator %s", len(delegations), validationID)
+
+	res, err := s.delegationCli.ResolveRewards(ctx, delegations, epochNum, epochEnd)
+	s.recordBatches(ctx, validationID, epochNum, res.Transactions)
+	if err != nil {
+		return res, fmt.Errorf(
+			"resolve rewards for %s (%d of %d delegations resolved, %d skipped): %w",
+			validationID,
+			res.Resolved,
+			len(delegations),
+			res.Skipped,
+			err,
+		)
 	}
+	logging.Infof("successfully resolved rewards for validator %s", validationID)
+	return res, nil
+}
 
-	submitted := make(map[string]bool)
-	for _, update := range gqlResp.Data.UptimeUpdates {
-		submitted[normalizeHex(update.ValidationID)] = true
+// recordBatches writes the resolveRewards transactions sent for
+// validationID to the transactions table.
+func (s *UptimeService) recordBatches(
+	ctx context.Context,
+	validationID string,
+	epochNum uint64,
+	batches []delegation.BatchTx,
+) {
+	if len(batches) == 0 {
+		return
+	}
+	valID, err := ids.FromString(validationID)
+	if err != nil {
+		logging.Errorf("not recording resolveRewards transactions for %s: %v", validationID, err)
+		return
 	}
 
-	var missingHexIDs []string
-	for hexID := range hexToProof {
-		if !submitted[hexID] {
-			missingHexIDs = append(missingHexIDs, hexID)
-		}
+	ctx = context.WithoutCancel(ctx)
+	for _, b := range batches {
+		rec := txRecord(
+			db.TxResolveRewards,
+			valID,
+			sql.NullInt64{Int64: int64(epochNum), Valid: true},
+			b.TxHash,
+			b.Nonce,
+			b.Receipt,
+			b.Err,
+		)
+		rec.DelegationCount = b.Delegations
+		recordTransaction(ctx, s.store, rec)
 	}
+}
 
-	logging.Infof(
-		"found %d validators missing from subgraph uptimeUpdates (epoch %s)",
-		len(missingHexIDs),
-		epochID,
-	)
+// SubmitMissingUptimeProofs checks the subgraph for missing uptime submissions
+// in each of the given epochs, and submits/re-signs proofs as needed. A
+// validator is submitted at most once per run, even when it is missing from
+// several epochs of a backfill range. It sends through the service's own
+// sender, so it shares nonces with every other step of a daemon cycle.
+func (s *UptimeService) SubmitMissingUptimeProofs(ctx context.Context, epochs []uint64) (err error) {
+	if len(epochs) == 0 {
+		return fmt.Errorf("no epochs to check")
+	}
 
-	if len(missingHexIDs) == 0 {
-		logging.Info("all uptime proofs appear to be submitted.")
-		return nil
+	command := fmt.Sprintf("submit-missing-uptime-proofs -epoch %d", epochs[0])
+	if len(epochs) > 1 {
+		command = fmt.Sprintf("submit-missing-uptime-proofs -epoch %d-%d", epochs[0], epochs[len(epochs)-1])
 	}
+	run := startRun(ctx, s.store, s.cfg, command)
+	defer func() { run.finish(ctx, err) }()
 
-	contractClient, err := contract.NewContractClient(
-		cfg.BeamRPC,
-		cfg.StakingManagerAddress,
-		cfg.WarpMessengerAddress,
-		cfg.PrivateKey,
-	)
+	proofs, err := s.store.GetAllUptimeProofs(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to init contract client: %w", err)
+		return fmt.Errorf("failed to fetch from DB: %w", err)
 	}
 
-	aggClient, err := aggregator.NewClient(
-		cfg.AggregatorURL,
-		uint32(cfg.NetworkID),
-		cfg.SigningSubnetID,
-		cfg.SourceChainId,
-		cfg.LogLevel,
-		cfg.QuorumPercentage,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to init aggregator client: %w", err)
+	hexToCB58 := map[string]string{}
+	hexToProof := map[string]db.UptimeProof{}
+
+	for cb58ID, proof := range proofs {
+		hexID := normalizeHex(proof.ValidationID.Hex())
+		hexToCB58[hexID] = cb58ID
+		hexToProof[hexID] = proof
 	}
 
+	gql := subgraph.NewClient(s.cfg.GraphQLEndpoint)
+	handled := make(map[string]bool)
 	failedValidators := make(map[string]string)
 
-	for _, hexID := range missingHexIDs {
-		proof := hexToProof[hexID]
+	for _, epochNum := range epochs {
+		if ctx.Err() != nil {
+			break
+		}
+		epochID := strconv.FormatUint(epochNum, 10)
+		logging.Infof("checking for missing uptime submissions in epoch %s", epochID)
 
-		err := contractClient.SubmitUptimeProof(proof.ValidationID, proof.SignedMessage)
-		if err != nil && strings.Contains(err.Error(), "invalid warp message") {
-			logging.Infof("expired warp message for %s — re-signing", hexID)
-			unsignedMsg, err := aggClient.PackValidationUptimeMessage(
-				hexToCB58[hexID],
-				proof.UptimeSeconds,
-				uint32(cfg.NetworkID),
-			)
-			if err != nil {
-				failedValidators[hexID] = fmt.Sprintf("re-sign pack error: %v", err)
-				continue
+		submitted, err := fetchUptimeUpdates(ctx, gql, epochID)
+		if err != nil {
+			return fmt.Errorf("epoch %s: %w", epochID, err)
+		}
+
+		var missingHexIDs []string
+		for hexID := range hexToProof {
+			if !submitted[hexID] {
+				missingHexIDs = append(missingHexIDs, hexID)
 			}
-			signedMsg, err := aggClient.SubmitAggregateRequest(unsignedMsg)
-			if err != nil {
-				failedValidators[hexID] = fmt.Sprintf("re-sign submit error: %v", err)
-				continue
+		}
+
+		logging.Infof(
+			"found %d validators missing from subgraph uptimeUpdates (epoch %s)",
+			len(missingHexIDs),
+			epochID,
+		)
+
+		for _, hexID := range missingHexIDs {
+			if ctx.Err() != nil {
+				break
 			}
-			err = contractClient.SubmitUptimeProof(proof.ValidationID, signedMsg)
-			if err != nil {
-				failedValidators[hexID] = fmt.Sprintf("resubmit error: %v", err)
+			if handled[hexID] {
+				logging.Infof("already handled %s earlier in this run, skipping", hexID)
 				continue
 			}
-			logging.Infof("✓ re-signed and submitted proof for %s (CB58: %s)", hexID, hexToCB58[hexID])
-		} else if err != nil {
-			failedValidators[hexID] = fmt.Sprintf("initial error: %v", err)
-			continue
-		} else {
-			logging.Infof("✓ submitted proof for %s (CB58: %s)", hexID, hexToCB58[hexID])
+			handled[hexID] = true
+
+			if err := s.resubmitStoredProof(ctx, epochNum, hexToCB58[hexID], hexToProof[hexID]); err != nil {
+				failedValidators[hexID] = err.Error()
+				run.add(hexToCB58[hexID], "failed", err)
+			} else {
+				run.add(hexToCB58[hexID], "submitted", nil)
+			}
 		}
 	}
 
+	interrupted := ctx.Err()
+	if len(handled) == 0 && interrupted == nil {
+		logging.Info("all uptime proofs appear to be submitted.")
+		return nil
+	}
+
+	prefix := ""
+	if s.cfg.DryRun {
+		prefix = "DRY RUN: "
+	}
+	logging.Infof(
+		"%ssubmit-missing summary: %d missing across %d epochs, %d submitted, %d failed",
+		prefix,
+		len(handled),
+		len(epochs),
+		len(handled)-len(failedValidators),
+		len(failedValidators),
+	)
+
 	if len(failedValidators) > 0 {
 		logging.Error("❌ the following validators failed and were skipped:")
 		for hexID, reason := range failedValidators {
 			logging.Errorf("- %s (CB58: %s): %s\n", hexID, hexToCB58[hexID], reason)
 		}
-	} else {
+	} else if interrupted == nil {
 		logging.Info("all missing uptime proofs successfully submitted.")
 	}
 
+	if interrupted != nil {
+		return fmt.Errorf("submit missing uptime proofs interrupted: %w", interrupted)
+	}
+	return nil
+}
+
+// fetchUptimeUpdates returns the normalized hex validation IDs that have an
+// uptimeUpdate recorded in the subgraph for epochID.
+func fetchUptimeUpdates(ctx context.Context, gql *subgraph.Client, epochID string) (map[string]bool, error) {
+	query := `
+	query getUptimeUpdates($epoch: BigInt!, $first: Int!, $lastID: Bytes!) {
+		uptimeUpdates(
+			first: $first,
+			orderBy: id,
+			orderDirection: asc,
+			where: { id_gt: $lastID, epoch: $epoch }
+		) {
+			id
+			validationID
+		}
+	}`
+
+	type uptimeUpdate struct {
+		ID           string `json:"id"`
+		ValidationID string `json:"validationID"`
+	}
+
+	updates, err := subgraph.Paginate(ctx, gql, subgraph.PageQuery{
+		Query:      query,
+		Field:      "uptimeUpdates",
+		Variables:  map[string]interface{}{"epoch": epochID},
+		StartAfter: "0x",
+	}, func(u uptimeUpdate) string { return u.ID })
+	if err != nil {
+		return nil, fmt.Errorf("failed to query uptime updates: %w", err)
+	}
+
+	submitted := make(map[string]bool, len(updates))
+	for _, update := range updates {
+		submitted[normalizeHex(update.ValidationID)] = true
+	}
+	return submitted, nil
+}
+
+// resubmitStoredProof submits a stored proof missing from epochNum,
+// re-signing it at the same uptime first if the stored warp message has
+// expired. Every submission is recorded in the proof history.
+func (s *UptimeService) resubmitStoredProof(
+	ctx context.Context,
+	epochNum uint64,
+	cb58ID string,
+	proof db.UptimeProof,
+) error {
+	hexID := normalizeHex(proof.ValidationID.Hex())
+	attempt := proofAttempt{
+		valID:     proof.ValidationID,
+		epoch:     sql.NullInt64{Int64: int64(epochNum), Valid: true},
+		uptime:    proof.UptimeSeconds,
+		signedMsg: proof.SignedMessage,
+		signedAt:  proof.UpdatedAt,
+	}
+
+	sub, err := s.contractCli.SubmitUptimeProof(ctx, proof.ValidationID, proof.SignedMessage)
+	recordSubmission(ctx, s.store, attempt, sub, err)
+	if errors.Is(err, contract.ErrInvalidWarpMessage) {
+		logging.Infof("expired warp message for %s — re-signing", hexID)
+		unsignedMsg, err := s.aggClient.PackValidationUptimeMessage(
+			cb58ID,
+			proof.UptimeSeconds,
+			uint32(s.cfg.NetworkID),
+		)
+		if err != nil {
+			return fmt.Errorf("re-sign pack error: %w", err)
+		}
+		signedMsg, err := s.aggClient.SubmitAggregateRequest(ctx, unsignedMsg)
+		if err != nil {
+			return fmt.Errorf("re-sign submit error: %w", err)
+		}
+		attempt.signedMsg, attempt.signedAt = signedMsg, time.Now()
+		sub, err := s.contractCli.SubmitUptimeProof(ctx, proof.ValidationID, signedMsg)
+		recordSubmission(ctx, s.store, attempt, sub, err)
+		if err != nil {
+			return fmt.Errorf("resubmit error: %w", err)
+		}
+		logging.Infof("✓ re-signed and submitted proof for %s (CB58: %s)", hexID, cb58ID)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("initial error: %w", err)
+	}
+
+	logging.Infof("✓ submitted proof for %s (CB58: %s)", hexID, cb58ID)
 	return nil
 }
@@ -0,0 +1,129 @@
+package subgraph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// PageSize is the largest page the graph node hands out per query.
+const PageSize = 1000
+
+type Request struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type response struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors,omitempty"`
+}
+
+type Client struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+func NewClient(endpoint string) *Client {
+	return &Client{
+		endpoint:   endpoint,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Query runs a single GraphQL query and returns the raw JSON of each
+// top-level field under "data".
+func (c *Client) Query(ctx context.Context, query string, variables map[string]interface{}) (map[string]json.RawMessage, error) {
+	jsonData, err := json.Marshal(Request{Query: query, Variables: variables})
+	if err != nil {
+		return nil, fmt.Errorf("marshal GraphQL query: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("execute GraphQL request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var graphqlResp response
+	if err := json.NewDecoder(resp.Body).Decode(&graphqlResp); err != nil {
+		return nil, fmt.Errorf("decode GraphQL response: %w", err)
+	}
+
+	if len(graphqlResp.Errors) > 0 {
+		return nil, fmt.Errorf("GraphQL error: %s", graphqlResp.Errors[0].Message)
+	}
+
+	return graphqlResp.Data, nil
+}
+
+// PageQuery describes a collection query to page through by entity ID.
+//
+// Query must declare `$first: Int!` and a `$lastID` variable of the entity's
+// ID type, and select the collection with
+//
+//	first: $first, orderBy: id, orderDirection: asc, where: { id_gt: $lastID, ... }
+//
+// and must select the `id` field. StartAfter is the initial cursor: "" for
+// String/ID keyed entities, "0x" for Bytes keyed ones.
+type PageQuery struct {
+	Query      string
+	Field      string
+	Variables  map[string]interface{}
+	StartAfter string
+}
+
+// Paginate runs q until the collection is exhausted, advancing the id_gt
+// cursor to the last ID of each page. Unlike skip, the cursor stays cheap
+// and stable however deep the collection goes.
+func Paginate[T any](ctx context.Context, c *Client, q PageQuery, idOf func(T) string) ([]T, error) {
+	variables := make(map[string]interface{}, len(q.Variables)+2)
+	for k, v := range q.Variables {
+		variables[k] = v
+	}
+	variables["first"] = PageSize
+
+	var all []T
+	cursor := q.StartAfter
+	for {
+		variables["lastID"] = cursor
+
+		data, err := c.Query(ctx, q.Query, variables)
+		if err != nil {
+			return nil, err
+		}
+
+		var page []T
+		if raw, ok := data[q.Field]; ok {
+			if err := json.Unmarshal(raw, &page); err != nil {
+				return nil, fmt.Errorf("decode %s page: %w", q.Field, err)
+			}
+		}
+		all = append(all, page...)
+
+		if len(page) < PageSize {
+			return all, nil
+		}
+
+		next := idOf(page[len(page)-1])
+		if next == "" || next == cursor {
+			return nil, fmt.Errorf("paginate %s: cursor did not advance past %q", q.Field, cursor)
+		}
+		cursor = next
+	}
+}
@@ -2,11 +2,13 @@ package validator
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"sort"
 	"sync"
+	"time"
 )
 
 type UptimeSample struct {
@@ -32,11 +34,21 @@ type rpcResponse struct {
 	} `json:"error"`
 }
 
-func FetchUptimesFromNode(apiBaseURL string) ([]UptimeSample, error) {
+// nodeHTTPClient queries the validators API. The timeout keeps a node that
+// accepts the connection but never answers from stalling the fetch.
+var nodeHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+func FetchUptimesFromNode(ctx context.Context, apiBaseURL string) ([]UptimeSample, error) {
 	reqBody := []byte(`{"jsonrpc":"2.0","id":1,"method":"validators.getCurrentValidators","params":{}}`)
 	url := apiBaseURL + "/validators"
 
-	resp, err := http.Post(url, "application/json", bytes.NewReader(reqBody))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("create request for %s: %w", apiBaseURL, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := nodeHTTPClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("call avalanche validators API (%s): %w", apiBaseURL, err)
 	}
@@ -72,40 +84,90 @@ func FetchUptimesFromNode(apiBaseURL string) ([]UptimeSample, error) {
 	return uptimes, nil
 }
 
-// FetchAggregatedUptimes fetches uptimes from multiple endpoints and aggregates them
-// into a map of validationID -> sorted slice of uptimeSeconds (descending).
-func FetchAggregatedUptimes(endpoints []string) map[string][]uint64 {
-	type safeMap struct {
-		sync.Mutex
-		data map[string][]uint64
+// NodeReport describes how one endpoint fared during FetchAggregatedUptimes.
+type NodeReport struct {
+	Endpoint       string
+	Latency        time.Duration
+	Err            error // nil if the node answered with a validator set
+	ValidatorCount int   // validators the node reported
+	// HealthScore is ValidatorCount relative to the most complete node in
+	// the same fetch: 1 for a full view of the validator set, 0 for a node
+	// that failed. Nodes that answer but lag behind score in between.
+	HealthScore float64
+}
+
+// FetchResult is the aggregated outcome of querying the node fleet.
+type FetchResult struct {
+	// Uptimes maps validationID -> uptime samples, sorted descending.
+	Uptimes map[string][]uint64
+	// Nodes holds one report per endpoint, in the order they were given.
+	Nodes []NodeReport
+}
+
+// Responded returns how many nodes answered successfully.
+func (r *FetchResult) Responded() int {
+	n := 0
+	for _, node := range r.Nodes {
+		if node.Err == nil {
+			n++
+		}
 	}
+	return n
+}
 
-	result := safeMap{data: make(map[string][]uint64)}
+// FetchAggregatedUptimes fetches uptimes from multiple endpoints and aggregates them
+// into a map of validationID -> sorted slice of uptimeSeconds (descending),
+// alongside a per-node report of latency, errors and validator counts. A
+// failing node never fails the fetch; it shows up in the report instead.
+func FetchAggregatedUptimes(ctx context.Context, endpoints []string) *FetchResult {
+	result := &FetchResult{
+		Uptimes: make(map[string][]uint64),
+		Nodes:   make([]NodeReport, len(endpoints)),
+	}
+	var mu sync.Mutex
 	var wg sync.WaitGroup
 
-	for _, endpoint := range endpoints {
+	for i, endpoint := range endpoints {
 		wg.Add(1)
-		go func(api string) {
+		go func(i int, api string) {
 			defer wg.Done()
-			uptimes, err := FetchUptimesFromNode(api)
-			if err != nil {
-				return // log if you want, but silently ignore one bad node
+			start := time.Now()
+			uptimes, err := FetchUptimesFromNode(ctx, api)
+			report := NodeReport{
+				Endpoint:       api,
+				Latency:        time.Since(start),
+				Err:            err,
+				ValidatorCount: len(uptimes),
 			}
-			result.Lock()
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.Nodes[i] = report
 			for _, u := range uptimes {
-				result.data[u.ValidationID] = append(result.data[u.ValidationID], u.UptimeSeconds)
+				result.Uptimes[u.ValidationID] = append(result.Uptimes[u.ValidationID], u.UptimeSeconds)
 			}
-			result.Unlock()
-		}(endpoint)
+		}(i, endpoint)
 	}
 
 	wg.Wait()
 
-	for id := range result.data {
-		slice := result.data[id]
+	for id := range result.Uptimes {
+		slice := result.Uptimes[id]
 		sort.Slice(slice, func(i, j int) bool { return slice[i] > slice[j] })
-		result.data[id] = slice
+		result.Uptimes[id] = slice
+	}
+
+	maxCount := 0
+	for _, node := range result.Nodes {
+		if node.Err == nil && node.ValidatorCount > maxCount {
+			maxCount = node.ValidatorCount
+		}
+	}
+	for i := range result.Nodes {
+		if result.Nodes[i].Err == nil && maxCount > 0 {
+			result.Nodes[i].HealthScore = float64(result.Nodes[i].ValidatorCount) / float64(maxCount)
+		}
 	}
 
-	return result.data
+	return result
 }